@@ -2,23 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"simple-agent/llm"
 	"simple-agent/logger"
+	"simple-agent/session"
 	"simple-agent/tools"
 	"strings"
+	"sync"
 
-	"github.com/imroc/req/v3"
 	"go.uber.org/zap"
 )
 
-// Message 表示与模型交互的消息结构
-type Message struct {
-	Role    string `json:"role"`    // 消息角色：system, user, assistant, tool
-	Content string `json:"content"` // 消息内容
-}
-
-const GLM_API_URL = "https://open.bigmodel.cn/api/paas/v4/chat/completions"
-
 // 预定义的系统提示词
 const DEFAULT_SYSTEM_PROMPT = `你是一个智能助手，你拥有强大的推理能力、稳定的代码生成和多工具协同处理能力，同时具备显著的运行速度优势。
 你支持最长128K的上下文处理，可高效应对长文本理解、多轮对话连续性和结构化内容生成等复杂任务。
@@ -38,13 +33,7 @@ const DEFAULT_SYSTEM_PROMPT = `你是一个智能助手，你拥有强大的推
 
 可用的工具：
 
-1. 文件操作工具 (file_operation)：
-   - list: 列出目录内容，参数：{"path": "目录路径"}
-   - read: 读取文件内容，参数：{"path": "文件路径"}
-   - write: 写入文件内容，参数：{"path": "文件路径", "content": "文件内容"}
-
-2. Shell命令工具 (shell_command)：
-   - execute: 执行Shell命令，参数：{"command": "要执行的命令"}
+{{TOOLS}}
 
 使用工具的规则：
 - 当前工作目录是项目的根目录，你可以直接使用相对路径访问项目文件
@@ -72,187 +61,531 @@ const DEFAULT_SYSTEM_PROMPT = `你是一个智能助手，你拥有强大的推
 
 注意：工具调用时只返回JSON格式，不要添加任何其他文字说明。如果用户输入为空，请友好地提示用户输入内容。`
 
+// TOOLS_PLACEHOLDER 系统提示词中工具列表的占位符，创建代理时替换为已启用工具的说明
+const TOOLS_PLACEHOLDER = "{{TOOLS}}"
+
+// 原生函数调用模式下的系统提示词，工具定义通过请求的 tools 字段下发
+const NATIVE_TOOLS_SYSTEM_PROMPT = `你是一个智能助手，你拥有强大的推理能力、稳定的代码生成和多工具协同处理能力，同时具备显著的运行速度优势。
+你支持最长128K的上下文处理，可高效应对长文本理解、多轮对话连续性和结构化内容生成等复杂任务。
+
+你可以回答用户的问题，也可以调用提供给你的工具来完成特定任务。
+
+使用工具的规则：
+- 当前工作目录是项目的根目录，你可以直接使用相对路径访问项目文件
+- 当用户要求分析代码时，首先查看项目结构，然后读取相关文件
+- 当用户要求创建、读取、修改文件时，使用文件操作工具
+- 当用户要求执行命令或运行程序时，使用Shell命令工具
+- 工具执行完成后，基于工具结果直接回答用户
+- 如果不需要使用工具，直接回答用户问题
+
+如果用户输入为空，请友好地提示用户输入内容。`
+
 // AgentConfig 代理配置
 type AgentConfig struct {
-	APIKey       string   // 智谱API密钥
-	SystemPrompt string   // 系统提示词
-	Tools        []string // 可用工具列表
+	Provider           string          // 模型提供方：zhipu, openai
+	APIKey             string          // 模型服务API密钥
+	BaseURL            string          // 模型接口基础地址，为空时使用提供方默认地址
+	Model              string          // 模型名称，为空时使用提供方默认模型
+	Stream             bool            // 是否使用流式输出，边接收边打印回复
+	NativeTools        bool            // 是否使用原生函数调用（tools/tool_calls），关闭时从回复文本中解析工具调用
+	Retry              llm.RetryPolicy // 模型请求失败时的重试策略
+	DisableThinking    bool            // 是否关闭模型的思考模式
+	ShowThinking       bool            // 是否默认展开显示思考过程，可通过 /thinking on|off 切换
+	MaxTurnTokens      int             // 单轮token预算，超出后停止工具循环，0表示不限制
+	MaxSessionTokens   int             // 会话token预算，超出后不再发起新的请求，0表示不限制
+	ContextWindow      int             // 模型上下文窗口（tokens），0时使用默认值
+	CompactThreshold   float64         // 估算用量达到窗口的该比例时自动压缩历史，0时使用默认值
+	KeepRecentTurns    int             // 压缩时原样保留的最近回合数，0时使用默认值
+	MaxToolOutputChars int             // 单条工具结果写入历史时的最大字符数，0时使用默认值
+	ToolWorkers        int             // 并行执行工具调用的最大并发数，0时使用默认值，1表示顺序执行
+	MaxToolRounds      int             // 单轮对话中工具调用的最大轮数，0时使用默认值
+	AutoApprove        bool            // 是否自动执行所有工具调用，关闭时写文件、执行命令等操作需要用户确认
+	PolicyFile         string          // 权限策略文件路径，文件不存在时只使用内置的默认规则
+	ToolOutput         string          // 工具结果的显示方式：quiet, normal, full，为空时使用 normal
+	IgnorePatterns     []string        // 搜索、列出文件时额外忽略的路径模式，语法与 .gitignore 相同
+	SessionDir         string          // 会话保存目录，为空时不保存会话
+	SystemPrompt       string          // 系统提示词
+	Tools              []string        // 启用的工具列表，每项为工具类型（file_operation）或具体工具（file_operation.read）
 }
 
 // AdvancedAgent 高级代理结构体
 type AdvancedAgent struct {
-	config         AgentConfig           // 代理配置
-	getUserMessage func() (string, bool) // 获取用户消息的函数
-	conversation   []Message             // 对话历史
+	config          AgentConfig                                      // 代理配置
+	model           llm.ChatModel                                    // 模型客户端
+	registry        *tools.Registry                                  // 已启用的工具
+	policy          *tools.Policy                                    // 工具调用的权限策略
+	toolSpecs       []llm.ToolSpec                                   // 原生函数调用模式下发送给模型的工具定义
+	getUserMessage  func() (string, bool)                            // 获取用户消息的函数
+	askUser         func(prompt, defaultValue string) (string, bool) // 向用户提问的函数，用于确认工具调用
+	approvals       approvals                                        // 本次会话中"总是允许"的工具和命令
+	conversation    []llm.Message                                    // 对话历史
+	showThinking    bool                                             // 是否展开显示思考过程
+	lastReasoning   string                                           // 最近一次模型回复的思考过程
+	toolOutput      string                                           // 工具结果的显示方式
+	lastToolResults []toolResult                                     // 最近一批工具调用的完整结果
+	usage           usageTracker                                     // token用量统计
+	sessions        *session.Store                                   // 会话存储，为 nil 时不保存会话
+	session         *session.Session                                 // 当前会话，第一条消息写入时创建
+
+	turnMu     sync.Mutex         // 保护 cancelTurn
+	cancelTurn context.CancelFunc // 取消当前回合，没有进行中的回合时为 nil
 }
 
-// 实现ToolExecutor接口
-func (a *AdvancedAgent) ExecuteFileOperation(tool tools.Tool) tools.ToolCallResponse {
-	// 直接调用tools包中的文件操作实现
-	return tools.ExecuteFileOperation(tool)
-}
+// NewAdvancedAgent 创建一个新的高级代理实例
+func NewAdvancedAgent(config AgentConfig, getUserMessage func() (string, bool), askUser func(prompt, defaultValue string) (string, bool)) (*AdvancedAgent, error) {
+	// 未指定模型时记录提供方的默认模型，便于会话中保存
+	if config.Model == "" {
+		config.Model = llm.DefaultModel(config.Provider)
+	}
 
-func (a *AdvancedAgent) ExecuteShellCommand(tool tools.Tool) tools.ToolCallResponse {
-	return tools.ExecuteShellCommand(tool)
-}
+	// 根据配置创建模型客户端
+	model, err := llm.NewChatModel(llm.Config{
+		Provider: config.Provider,
+		APIKey:   config.APIKey,
+		BaseURL:  config.BaseURL,
+		Model:    config.Model,
+		Thinking: !config.DisableThinking,
+	})
+	if err != nil {
+		return nil, err
+	}
+	model = llm.WithRetry(model, config.Retry)
 
-// NewAdvancedAgent 创建一个新的高级代理实例
-func NewAdvancedAgent(config AgentConfig, getUserMessage func() (string, bool)) *AdvancedAgent {
-	// 初始化对话历史，添加系统提示
-	conversation := []Message{
+	// 上下文管理配置使用默认值补齐
+	if config.ContextWindow <= 0 {
+		config.ContextWindow = DEFAULT_CONTEXT_WINDOW
+	}
+	if config.CompactThreshold <= 0 {
+		config.CompactThreshold = DEFAULT_COMPACT_THRESHOLD
+	}
+	if config.KeepRecentTurns <= 0 {
+		config.KeepRecentTurns = DEFAULT_KEEP_RECENT_TURNS
+	}
+	if config.MaxToolOutputChars <= 0 {
+		config.MaxToolOutputChars = DEFAULT_MAX_TOOL_OUTPUT_CHARS
+	}
+
+	// 打开会话存储
+	var sessions *session.Store
+	if config.SessionDir != "" {
+		sessions, err = session.NewStore(config.SessionDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 按配置启用工具，并把工具说明填入系统提示词
+	registry, err := tools.DefaultRegistry().Filter(config.Tools)
+	if err != nil {
+		return nil, err
+	}
+	config.SystemPrompt = strings.Replace(config.SystemPrompt, TOOLS_PLACEHOLDER, registry.Describe(), 1)
+
+	// 初始化对话历史，添加填入工具说明后的系统提示，保存会话时一并写入
+	conversation := []llm.Message{
 		{Role: "system", Content: config.SystemPrompt},
 	}
 
+	// 搜索、列出文件时额外忽略的路径
+	tools.SetIgnorePatterns(config.IgnorePatterns)
+
+	// 工具结果默认截断显示
+	switch config.ToolOutput {
+	case "":
+		config.ToolOutput = TOOL_OUTPUT_NORMAL
+	case TOOL_OUTPUT_QUIET, TOOL_OUTPUT_NORMAL, TOOL_OUTPUT_FULL:
+	default:
+		return nil, fmt.Errorf("无效的工具结果显示方式: %s，可选值: quiet, normal, full", config.ToolOutput)
+	}
+
+	// 加载工具调用的权限策略
+	policy := tools.DefaultPolicy()
+	if config.PolicyFile != "" {
+		if policy, err = tools.LoadPolicy(config.PolicyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// 原生函数调用模式下，将工具定义转换为请求中的 tools 字段
+	var toolSpecs []llm.ToolSpec
+	if config.NativeTools {
+		for _, definition := range registry.Definitions() {
+			toolSpecs = append(toolSpecs, llm.ToolSpec{
+				Name:        definition.FunctionName(),
+				Description: definition.Description,
+				Parameters:  definition.Parameters,
+			})
+		}
+	}
+
 	return &AdvancedAgent{
 		config:         config,
+		model:          model,
+		registry:       registry,
+		policy:         policy,
+		toolSpecs:      toolSpecs,
+		showThinking:   config.ShowThinking,
+		toolOutput:     config.ToolOutput,
+		sessions:       sessions,
 		getUserMessage: getUserMessage,
+		askUser:        askUser,
 		conversation:   conversation,
-	}
+	}, nil
 }
 
 // Run 运行高级代理的主循环
 func (a *AdvancedAgent) Run(ctx context.Context) error {
-	fmt.Println("可用工具: " + strings.Join(a.config.Tools, ", "))
+	var enabled []string
+	for _, definition := range a.registry.Definitions() {
+		enabled = append(enabled, definition.Key())
+	}
+	fmt.Println("可用工具: " + strings.Join(enabled, ", "))
+	if source := a.policy.Source(); source != "" {
+		fmt.Printf("已加载权限策略: %s（%d 条规则）\n", source, len(a.policy.Rules))
+	}
 
-	for {
+	for ctx.Err() == nil {
 		// 获取用户输入（readline已经处理了提示符）
 		userInput, ok := a.getUserMessage()
 		if !ok {
 			break
 		}
 
-		// 检查是否是工具调用
-		if strings.HasPrefix(userInput, "/tool") {
-			// 处理工具调用
-			tools.HandleToolCall(ctx, userInput, a)
+		// 检查是否是 REPL 命令
+		if a.handleCommand(ctx, userInput) {
 			continue
 		}
 
-		// 添加用户消息到对话历史
-		userMessage := Message{Role: "user", Content: userInput}
-		a.conversation = append(a.conversation, userMessage)
+		// 每个回合使用独立的可取消上下文，中断回合不影响整个会话
+		turnCtx := a.beginTurn(ctx)
+		a.runTurn(turnCtx, userInput)
+		a.endTurn()
+
+		if ctx.Err() != nil {
+			// 整个会话已取消
+			logger.Info("会话已取消，停止当前回合")
+			return nil
+		}
+		if turnCtx.Err() != nil {
+			// 仅当前回合被中断，记录到历史中让模型知道发生了什么
+			a.appendMessage(llm.Message{Role: "assistant", Content: TURN_INTERRUPTED_NOTE})
+			printError("当前回合已被中断")
+		}
+	}
+
+	return nil
+}
+
+// TURN_INTERRUPTED_NOTE 回合被用户中断时写入对话历史的说明
+const TURN_INTERRUPTED_NOTE = "[已中断] 用户中断了本轮操作，回复和尚未完成的工具调用均未执行完毕。"
+
+// beginTurn 为新回合创建可取消的上下文，并登记取消函数供 Interrupt 使用
+func (a *AdvancedAgent) beginTurn(ctx context.Context) context.Context {
+	turnCtx, cancel := context.WithCancel(ctx)
+
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	a.cancelTurn = cancel
+	return turnCtx
+}
+
+// endTurn 结束当前回合并释放其上下文
+func (a *AdvancedAgent) endTurn() {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	if a.cancelTurn != nil {
+		a.cancelTurn()
+		a.cancelTurn = nil
+	}
+}
+
+// Interrupt 中断正在进行的回合，返回是否有回合被中断；可以在其他 goroutine 中调用
+func (a *AdvancedAgent) Interrupt() bool {
+	a.turnMu.Lock()
+	defer a.turnMu.Unlock()
+	if a.cancelTurn == nil {
+		return false
+	}
+	a.cancelTurn()
+	a.cancelTurn = nil
+	return true
+}
+
+// runTurn 处理一轮用户输入：调用模型，执行工具调用直到模型给出最终回复
+func (a *AdvancedAgent) runTurn(ctx context.Context, userInput string) {
+	// 会话预算用完后不再发起新的回合
+	a.usage.beginTurn()
+	if reason := a.usage.exceeded(0, a.config.MaxSessionTokens); reason != "" {
+		printError(reason + "，无法继续对话")
+		return
+	}
+
+	// 添加用户消息到对话历史
+	userMessage := llm.Message{Role: "user", Content: userInput}
+	a.appendMessage(userMessage)
 
-		// 调用模型获取回复
-		message, err := a.runInference(ctx, a.conversation)
+	// 历史过长时先压缩再请求
+	a.maybeCompact(ctx)
+
+	// 调用模型获取回复
+	message, err := a.runInference(ctx, a.conversation, true)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		logger.Error("调用模型获取回复错误", zap.Error(err))
+		printError(fmt.Sprintf("调用模型失败，本轮未获得回复: %v", err))
+		return
+	}
+
+	// 处理多轮工具调用
+	guard := newToolLoopGuard(a.config.MaxToolRounds)
+	corrections := 0
+	for {
+		// 检查回复中是否包含工具调用
+		extractedTools, invalid, err := a.extractToolCalls(message)
 		if err != nil {
-			logger.Error("调用模型获取回复错误", zap.Error(err))
+			// 工具调用无法解析时把错误告诉模型，让它修正后重新输出；多次仍然失败时把这条回复当作最终回答
+			corrections++
+			if corrections > MAX_TOOL_CALL_CORRECTIONS {
+				logger.Warn("模型多次给出无法解析的工具调用，停止重试", zap.Error(err))
+				printError(fmt.Sprintf("模型连续 %d 次给出无法解析的工具调用，已停止重试", MAX_TOOL_CALL_CORRECTIONS))
+				break
+			}
+			logger.Warn("工具调用无法解析，要求模型修正", zap.Int("次数", corrections), zap.Error(err))
+			a.appendMessage(message)
+			a.appendMessage(llm.Message{Role: "user", Content: fmt.Sprintf(TOOL_CALL_CORRECTION_PROMPT, err.Error())})
+
+			var ok bool
+			if message, ok = a.followUp(ctx); !ok {
+				return
+			}
 			continue
 		}
+		if len(extractedTools) == 0 {
+			break // 没有工具调用，退出循环
+		}
 
-		// 处理多轮工具调用
-		for {
-			// 检查回复中是否包含工具调用
-			extractedTools, hasTools := tools.ExtractTools(message.Content)
-			if !hasTools {
-				break // 没有工具调用，退出循环
+		logger.Debug("检测到工具调用", zap.Int("数量", len(extractedTools)))
+
+		// 轮数过多或反复发起相同调用时停止工具循环，要求模型直接给出最终回答
+		if reason := guard.check(extractedTools); reason != "" {
+			var ok bool
+			if message, ok = a.stopToolLoop(ctx, reason); !ok {
+				return
 			}
+			break
+		}
 
-			logger.Debug("检测到工具调用", zap.Int("数量", len(extractedTools)))
+		// 先记录发起调用的 assistant 消息，工具结果紧随其后，模型才能看到完整的调用过程；
+		// 原生函数调用还要求 tool 消息通过 tool_call_id 对应到这条消息中的调用
+		a.appendMessage(message)
+
+		// 调用未知工具或参数无法解析的调用不执行，原因作为结果告诉模型；多次修正仍然失败时整轮都不执行
+		gaveUp := false
+		if hasInvalidToolCalls(invalid) {
+			corrections++
+			if gaveUp = corrections > MAX_TOOL_CALL_CORRECTIONS; gaveUp {
+				for i := range invalid {
+					if invalid[i] == "" {
+						invalid[i] = "未执行：本轮工具调用已停止"
+					}
+				}
+			}
+		}
 
-			// 处理工具调用
-			responses := tools.ExecuteTools(ctx, extractedTools, a)
+		// 确认并执行工具调用，无效、被拒绝或因回合中断未执行的调用也会有结果，保证每个调用都有回复
+		responses := a.executeToolCalls(ctx, extractedTools, invalid)
 
-			logger.Debug("工具调用执行完成", zap.Int("响应数量", len(responses)))
+		logger.Debug("工具调用执行完成", zap.Int("响应数量", len(responses)))
 
-			// 将工具调用结果添加到对话历史
-			toolResponseMsg := Message{
+		// 将工具调用结果添加到对话历史
+		if len(message.ToolCalls) > 0 {
+			// 原生模式下每个调用单独回复一条 tool 消息
+			for i, response := range responses {
+				a.appendMessage(llm.Message{
+					Role:       "tool",
+					Content:    truncateToolOutput(tools.FormatToolResponse(response), a.config.MaxToolOutputChars),
+					ToolCallID: extractedTools[i].ID,
+				})
+			}
+		} else {
+			// 文本模式下所有结果合并为一条 tool 消息，逐条注明对应的调用；没有调用ID，发送给模型时改为 user 角色
+			toolResponseMsg := llm.Message{
 				Role:    "tool",
-				Content: tools.FormatToolResponses(responses),
+				Content: truncateToolOutput(tools.FormatToolResponses(extractedTools, responses), a.config.MaxToolOutputChars),
 			}
-			a.conversation = append(a.conversation, toolResponseMsg)
+			a.appendMessage(toolResponseMsg)
 
 			logger.Debug("工具响应内容", zap.String("内容", toolResponseMsg.Content))
+		}
 
-			// 再次调用模型获取回复
-			message, err = a.runInference(ctx, a.conversation)
-			if err != nil {
-				logger.Error("模型推理失败", zap.Error(err))
-				break
-			}
+		if gaveUp {
+			reason := fmt.Sprintf("模型连续 %d 次调用了无效的工具", MAX_TOOL_CALL_CORRECTIONS)
+			logger.Warn("停止工具循环", zap.String("原因", reason))
+			a.appendMessage(llm.Message{
+				Role:    "assistant",
+				Content: fmt.Sprintf("[已停止] %s，未继续处理。", reason),
+			})
+			printError(fmt.Sprintf("已停止本轮工具调用: %s", reason))
+			printUsage(a.usage.summary())
+			return
 		}
 
-		// 添加模型回复到对话历史
-		a.conversation = append(a.conversation, message)
+		// 超出预算时停止工具循环，并在历史中说明本轮为何没有最终回复
+		if reason := a.usage.exceeded(a.config.MaxTurnTokens, a.config.MaxSessionTokens); reason != "" {
+			a.appendMessage(llm.Message{
+				Role:    "assistant",
+				Content: fmt.Sprintf("[已停止] %s，未继续处理工具执行结果。", reason),
+			})
+			printError(fmt.Sprintf("已停止本轮工具调用: %s", reason))
+			printUsage(a.usage.summary())
+			return
+		}
 
-		// 打印模型回复
-		fmt.Printf("\u001b[93m Vcode \u001b[0m: %s\n", message.Content)
+		// 再次调用模型获取回复
+		var ok bool
+		if message, ok = a.followUp(ctx); !ok {
+			return
+		}
 	}
 
-	return nil
+	// 添加模型回复到对话历史
+	a.appendMessage(message)
+
+	// 打印模型回复，流式模式下已经实时输出过
+	if !a.config.Stream {
+		printReply(message.Content)
+	}
+	printUsage(a.usage.summary())
 }
 
-// runInference 调用智谱API获取模型回复
-func (a *AdvancedAgent) runInference(_ctx context.Context, conversation []Message) (Message, error) {
-	// 构建请求体
-	requestBody := map[string]interface{}{
-		"model":    "glm-4.5-flash", // 使用GLM-4.5-Flash模型
-		"messages": conversation,
-		"thinking": map[string]string{
-			"type": "enabled", // 启用动态思考模式
-		},
-		"stream": false, // 暂时不使用流式输出
-	}
-
-	// 创建HTTP客户端
-	client := req.C()
-
-	// 发送请求到智谱API
-	logger.Debug("正在发送请求到智谱API")
-	resp, err := client.R().
-		SetHeader("Authorization", "Bearer "+a.config.APIKey).
-		SetHeader("Content-Type", "application/json").
-		SetBody(requestBody).
-		Post(GLM_API_URL)
+// followUp 工具结果或修正提示写入历史后再次请求模型，拿不到回复时向用户说明并返回 false
+func (a *AdvancedAgent) followUp(ctx context.Context) (llm.Message, bool) {
+	// 工具结果可能使历史迅速变长，请求前再检查一次
+	a.maybeCompact(ctx)
 
+	message, err := a.runInference(ctx, a.conversation, true)
+	if ctx.Err() != nil {
+		return llm.Message{}, false
+	}
 	if err != nil {
-		logger.Error("API请求错误", zap.Error(err))
-		return Message{}, err
+		// 没有拿到回复时不能把空消息当作最终回复写入历史
+		logger.Error("模型推理失败", zap.Error(err))
+		printError(fmt.Sprintf("调用模型失败，工具执行结果未能得到回复: %v", err))
+		return llm.Message{}, false
 	}
+	return message, true
+}
 
-	// 打印响应状态码
-	logger.Debug("API响应状态码", zap.Int("状态码", resp.StatusCode))
-
-	// 获取原始响应内容用于调试
-	rawBody, _ := resp.ToBytes()
+// stopToolLoop 强制停止工具循环：丢弃本次未执行的调用，告知模型原因并请它不调用工具直接回答，
+// 拿不到回答时向用户说明原因并返回 false
+func (a *AdvancedAgent) stopToolLoop(ctx context.Context, reason string) (llm.Message, bool) {
+	logger.Warn("停止工具循环", zap.String("原因", reason))
+	fmt.Printf("%s%s，已停止调用工具并要求模型直接回答%s\n", colorDim, reason, colorReset)
 
-	// 检查HTTP状态码
-	if resp.StatusCode != 200 {
-		logger.Error("API返回错误状态码", zap.Int("状态码", resp.StatusCode), zap.String("错误响应", string(rawBody)))
-		return Message{}, fmt.Errorf("API返回错误状态码: %d", resp.StatusCode)
+	a.appendMessage(llm.Message{Role: "user", Content: fmt.Sprintf(TOOL_LOOP_STOP_PROMPT, reason)})
+	message, err := a.runInference(ctx, a.conversation, false)
+	if ctx.Err() != nil {
+		return llm.Message{}, false
+	}
+	if err != nil {
+		logger.Error("停止工具循环后获取最终回答失败", zap.Error(err))
+		printError(fmt.Sprintf("%s，且未能获得最终回答: %v", reason, err))
+		return llm.Message{}, false
 	}
 
-	// 解析响应
-	var response struct {
-		Choices []struct {
-			Message Message `json:"message"`
-		} `json:"choices"`
-		Error struct {
-			Message string `json:"message"`
-			Type    string `json:"type"`
-			Code    string `json:"code"`
-		} `json:"error"`
+	// 即使模型仍然给出了调用也不再执行，去掉原生调用以免历史中出现没有结果的调用
+	message.ToolCalls = nil
+	return message, true
+}
+
+// runInference 调用模型获取回复，allowTools 为 false 时禁止模型调用工具
+func (a *AdvancedAgent) runInference(ctx context.Context, conversation []llm.Message, allowTools bool) (llm.Message, error) {
+	opts := llm.Options{Tools: a.toolSpecs}
+	if !allowTools && len(opts.Tools) > 0 {
+		opts.ToolChoice = "none"
+	}
+	if a.config.Stream {
+		// 流式模式下边接收边打印，完整消息仍由 Complete 返回
+		printer := &streamPrinter{showThinking: a.showThinking}
+		opts.OnDelta = printer.onDelta
+		defer printer.finish()
 	}
 
-	err = resp.UnmarshalJson(&response)
+	response, err := a.model.Complete(ctx, conversation, opts)
 	if err != nil {
-		logger.Error("解析API响应失败", zap.Error(err), zap.String("原始响应", string(rawBody)))
-		return Message{}, fmt.Errorf("解析API响应失败: %v, 原始响应: %s", err, string(rawBody))
+		return llm.Message{}, err
 	}
+	a.usage.record(response.Usage)
 
-	// 检查是否有API错误
-	if response.Error.Message != "" {
-		logger.Error("API错误", zap.String("消息", response.Error.Message), zap.String("类型", response.Error.Type), zap.String("代码", response.Error.Code))
-		return Message{}, fmt.Errorf("API错误: %s (类型: %s, 代码: %s)",response.Error.Message, response.Error.Type, response.Error.Code)
+	// 记录最近一次思考过程，非流式模式下在此统一打印
+	if response.Message.ReasoningContent != "" {
+		a.lastReasoning = response.Message.ReasoningContent
+		if !a.config.Stream {
+			printReasoning(response.Message.ReasoningContent, a.showThinking)
+		}
 	}
 
-	// 检查是否有有效回复
-	if len(response.Choices) == 0 {
-		logger.Error("API返回了空的choices数组")
-		return Message{}, fmt.Errorf("API返回了空回复")
+	return response.Message, nil
+}
+
+// extractToolCalls 提取模型回复中的工具调用，优先使用原生 tool_calls，否则回退到从回复文本中解析。
+// 返回的 invalid 与调用一一对应，记录不能执行的原因（未知工具、参数不是有效的JSON），可以执行的项为空；
+// 文本模式下回复中的工具调用无法解析时返回错误
+func (a *AdvancedAgent) extractToolCalls(message llm.Message) ([]tools.Tool, []string, error) {
+	var extracted []tools.Tool
+	var invalid []string
+
+	if len(message.ToolCalls) == 0 {
+		calls, err := tools.ExtractTools(message.Content)
+		if err != nil {
+			return nil, nil, err
+		}
+		extracted = calls
+		invalid = make([]string, len(calls))
+	} else {
+		for _, call := range message.ToolCalls {
+			tool := tools.Tool{ID: call.ID, Name: call.Function.Name}
+			problem := ""
+			if toolType, toolName, ok := tools.ParseFunctionName(call.Function.Name); ok {
+				tool.Type = toolType
+				tool.Name = toolName
+			}
+
+			// 参数为空时按无参数处理，解析失败时不执行该调用
+			if strings.TrimSpace(call.Function.Arguments) != "" {
+				if err := json.Unmarshal([]byte(call.Function.Arguments), &tool.Args); err != nil {
+					logger.Warn("解析工具调用参数失败", zap.Error(err), zap.String("参数", call.Function.Arguments))
+					problem = fmt.Sprintf("参数不是有效的 JSON 对象（%v），工具没有执行", err)
+				}
+			}
+			extracted = append(extracted, tool)
+			invalid = append(invalid, problem)
+		}
 	}
 
-	// 打印模型返回的原始内容用于调试
-	logger.Debug("模型返回的原始内容", zap.String("内容", response.Choices[0].Message.Content))
+	// 未知工具不执行，错误信息中列出可用工具；参数无法解析时附上期望的参数格式
+	for i, call := range extracted {
+		handler, err := a.registry.Resolve(call)
+		if err != nil {
+			invalid[i] = err.Error()
+			continue
+		}
+		if invalid[i] != "" {
+			expected, _ := json.Marshal(handler.Definition().Parameters)
+			invalid[i] += fmt.Sprintf("。期望的参数格式: %s", expected)
+		}
+	}
+	return extracted, invalid, nil
+}
 
-	return response.Choices[0].Message, nil
+// hasInvalidToolCalls 判断是否有不能执行的调用
+func hasInvalidToolCalls(invalid []string) bool {
+	for _, problem := range invalid {
+		if problem != "" {
+			return true
+		}
+	}
+	return false
 }
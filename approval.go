@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"simple-agent/logger"
+	"simple-agent/tools"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// shellControlChars 命令中出现这些字符时可能串联其他命令，不能按命令前缀自动批准
+const shellControlChars = ";&|`$<>\n"
+
+// approvals 本次会话中用户选择"总是允许"的工具和命令前缀
+type approvals struct {
+	tools           map[string]bool // 按 type.name 记录
+	commandPrefixes []string        // Shell命令前缀，如 "go test"
+}
+
+// allowed 判断调用是否已被"总是允许"
+func (p *approvals) allowed(call tools.Tool) bool {
+	if p.tools[call.Type+"."+call.Name] {
+		return true
+	}
+	command, ok := shellCommand(call)
+	if !ok || strings.ContainsAny(command, shellControlChars) {
+		return false
+	}
+	for _, prefix := range p.commandPrefixes {
+		if command == prefix || strings.HasPrefix(command, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// remember 记住"总是允许"的选择，Shell命令按前缀记住，其他工具按工具记住，返回记住的范围
+func (p *approvals) remember(call tools.Tool) string {
+	if command, ok := shellCommand(call); ok {
+		prefix := commandPrefix(command)
+		p.commandPrefixes = append(p.commandPrefixes, prefix)
+		return fmt.Sprintf("以 %q 开头的命令", prefix)
+	}
+	if p.tools == nil {
+		p.tools = make(map[string]bool)
+	}
+	p.tools[call.Type+"."+call.Name] = true
+	return fmt.Sprintf("工具 %s.%s", call.Type, call.Name)
+}
+
+// shellCommand 返回 Shell 命令调用中的命令
+func shellCommand(call tools.Tool) (string, bool) {
+	if call.Type != tools.TOOL_SHELL_COMMAND {
+		return "", false
+	}
+	command, ok := call.Args["command"].(string)
+	return strings.TrimSpace(command), ok
+}
+
+// commandPrefix 取命令的程序名，第二个词是子命令（如 git status、go test）时一并保留
+func commandPrefix(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	if len(fields) > 1 && isSubcommand(fields[1]) {
+		return fields[0] + " " + fields[1]
+	}
+	return fields[0]
+}
+
+// isSubcommand 判断是否像子命令：只包含小写字母、数字和连字符，且不以连字符开头
+func isSubcommand(word string) bool {
+	if word == "" || word[0] == '-' {
+		return false
+	}
+	for _, r := range word {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// needsApproval 判断没有策略规则命中的调用执行前是否需要用户确认：只读工具、未知工具和已"总是允许"的调用不需要
+func (a *AdvancedAgent) needsApproval(call tools.Tool) bool {
+	if a.config.AutoApprove {
+		return false
+	}
+	handler, ok := a.registry.Lookup(call.Type, call.Name)
+	if !ok || handler.Definition().ReadOnly {
+		return false
+	}
+	return !a.approvals.allowed(call)
+}
+
+// authorize 按权限策略和用户确认决定调用能否执行，不能执行时返回给模型的原因
+func (a *AdvancedAgent) authorize(ctx context.Context, call *tools.Tool) (bool, bool, string) {
+	decision := a.policy.Evaluate(*call)
+	switch decision.Action {
+	case tools.POLICY_DENY:
+		logger.Info("权限策略拒绝工具调用", zap.String("工具", call.Type+"."+call.Name), zap.String("规则", decision.Rule.Name))
+		return false, false, decision.Explain()
+	case tools.POLICY_ALLOW:
+		return true, false, ""
+	case tools.POLICY_ASK:
+		// 策略要求确认的调用不受自动执行影响，但本次会话中"总是允许"过的可以直接执行
+		if a.approvals.allowed(*call) {
+			return true, false, ""
+		}
+	default:
+		if !a.needsApproval(*call) {
+			return true, false, ""
+		}
+	}
+
+	if ctx.Err() != nil {
+		return false, false, "回合已中断，工具调用未执行"
+	}
+	approved, edited := a.askApproval(call)
+	if !approved {
+		return false, false, "用户拒绝执行该工具调用"
+	}
+
+	// 编辑后的参数需要重新检查策略，避免改成被禁止的调用
+	if edited {
+		if decision := a.policy.Evaluate(*call); decision.Action == tools.POLICY_DENY {
+			printError(fmt.Sprintf("修改后的调用被权限策略规则 %q 拒绝", decision.Rule.Name))
+			return false, false, decision.Explain()
+		}
+	}
+	return true, edited, ""
+}
+
+// executeToolCalls 逐个按权限策略检查并在需要时请用户确认后执行，invalid 中有原因的调用直接返回该原因不执行，
+// 未执行的调用也返回原因；用户编辑过参数的调用会替换 calls 中对应的项，结果中注明参数已被修改
+func (a *AdvancedAgent) executeToolCalls(ctx context.Context, calls []tools.Tool, invalid []string) []tools.ToolCallResponse {
+	responses := make([]tools.ToolCallResponse, len(calls))
+	edited := make([]bool, len(calls))
+	var runnable []tools.Tool
+	var indexes []int
+
+	for i := range calls {
+		printToolCall(calls[i])
+		if invalid[i] != "" {
+			responses[i] = tools.ToolCallResponse{Error: invalid[i]}
+			continue
+		}
+		allowed, changed, reason := a.authorize(ctx, &calls[i])
+		if !allowed {
+			responses[i] = tools.ToolCallResponse{Error: reason}
+			continue
+		}
+		edited[i] = changed
+		runnable = append(runnable, calls[i])
+		indexes = append(indexes, i)
+	}
+
+	results := tools.ExecuteTools(tools.WithPolicy(ctx, a.policy), runnable, a.registry, a.config.ToolWorkers)
+	for j, i := range indexes {
+		responses[i] = results[j]
+		if edited[i] {
+			args, _ := json.Marshal(calls[i].Args)
+			responses[i].Result = fmt.Sprintf("（用户修改了调用参数，实际执行的参数: %s）\n%s", args, responses[i].Result)
+		}
+	}
+
+	// 显示本批结果，并保留完整结果供 /expand 展开
+	a.lastToolResults = make([]toolResult, len(calls))
+	for i := range calls {
+		a.lastToolResults[i] = toolResult{call: calls[i], response: responses[i]}
+	}
+	printToolResults(a.lastToolResults, a.toolOutput)
+	return responses
+}
+
+// askApproval 显示调用详情并询问用户是否执行，返回是否批准以及参数是否被修改
+func (a *AdvancedAgent) askApproval(call *tools.Tool) (bool, bool) {
+	printToolApproval(*call)
+
+	changed := false
+	for {
+		answer, ok := a.askUser("允许执行？[y]允许 [n]拒绝 [a]总是允许 [e]编辑参数: ", "")
+		if !ok {
+			// 确认时按 Ctrl+C 或 Ctrl+D 视为拒绝，并中断本轮
+			a.Interrupt()
+			return false, changed
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			logger.Info("用户允许工具调用", zap.String("工具", call.Type+"."+call.Name))
+			return true, changed
+		case "n", "no":
+			logger.Info("用户拒绝工具调用", zap.String("工具", call.Type+"."+call.Name))
+			return false, changed
+		case "a", "always":
+			scope := a.approvals.remember(*call)
+			fmt.Printf("%s本次会话中将自动允许%s%s\n", colorDim, scope, colorReset)
+			return true, changed
+		case "e", "edit":
+			if a.editToolArgs(call) {
+				changed = true
+				printToolApproval(*call)
+			}
+		default:
+			fmt.Println("请输入 y、n、a 或 e")
+		}
+	}
+}
+
+// editToolArgs 让用户编辑调用参数，Shell 命令直接编辑命令文本，其他工具编辑 JSON 参数
+func (a *AdvancedAgent) editToolArgs(call *tools.Tool) bool {
+	if command, ok := shellCommand(*call); ok {
+		edited, ok := a.askUser("命令: ", command)
+		if !ok || strings.TrimSpace(edited) == "" {
+			return false
+		}
+		call.Args["command"] = strings.TrimSpace(edited)
+		return true
+	}
+
+	current, _ := json.Marshal(call.Args)
+	edited, ok := a.askUser("参数: ", string(current))
+	if !ok {
+		return false
+	}
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(edited), &args); err != nil {
+		printError(fmt.Sprintf("参数不是有效的JSON对象: %v", err))
+		return false
+	}
+	call.Args = args
+	return true
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"simple-agent/tools"
+	"strconv"
+	"strings"
+)
+
+// handleCommand 处理以 / 开头的 REPL 命令，返回 true 表示输入已作为命令处理
+func (a *AdvancedAgent) handleCommand(ctx context.Context, input string) bool {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch fields[0] {
+	case "/tool":
+		a.handleToolCommand(ctx, input)
+
+	case "/expand":
+		a.handleExpandCommand(fields[1:])
+
+	case "/verbose":
+		a.handleVerboseCommand(fields[1:])
+
+	case "/thinking":
+		a.handleThinkingCommand(fields[1:])
+
+	case "/compact":
+		a.handleCompactCommand(ctx)
+
+	case "/sessions":
+		a.handleSessionsCommand()
+
+	case "/save":
+		a.handleSaveCommand(fields[1:])
+
+	case "/load":
+		a.handleLoadCommand(fields[1:])
+
+	case "/usage":
+		fmt.Println(a.usage.report(a.config.MaxTurnTokens, a.config.MaxSessionTokens))
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// handleThinkingCommand 处理 /thinking 命令：无参数时展开最近一次思考过程，on/off 切换默认显示方式
+func (a *AdvancedAgent) handleThinkingCommand(args []string) {
+	if len(args) == 0 {
+		if a.lastReasoning == "" {
+			fmt.Println("暂无思考过程")
+			return
+		}
+		printReasoning(a.lastReasoning, true)
+		return
+	}
+
+	switch args[0] {
+	case "on":
+		a.showThinking = true
+		fmt.Println("已开启思考过程显示")
+	case "off":
+		a.showThinking = false
+		fmt.Println("已折叠思考过程显示")
+	default:
+		fmt.Println("用法: /thinking [on|off]")
+	}
+}
+
+// handleCompactCommand 处理 /compact 命令，立即压缩较早的对话历史
+func (a *AdvancedAgent) handleCompactCommand(ctx context.Context) {
+	before := estimateTokens(a.conversation)
+	compacted, err := a.compact(ctx)
+	if err != nil {
+		printError(fmt.Sprintf("压缩对话历史失败: %v", err))
+		return
+	}
+	if !compacted {
+		fmt.Printf("对话不超过 %d 个回合，无需压缩\n", a.config.KeepRecentTurns)
+		return
+	}
+	fmt.Printf("对话历史已压缩: 约 %d tokens -> 约 %d tokens\n", before, estimateTokens(a.conversation))
+}
+
+// handleToolCommand 处理 /tool 命令：不带参数时列出可用工具，否则按权限策略检查后执行指定的工具并显示结果
+func (a *AdvancedAgent) handleToolCommand(ctx context.Context, input string) {
+	call, err := tools.ParseToolCommand(input)
+	if err != nil {
+		printError(err.Error())
+		return
+	}
+	if call == nil {
+		fmt.Println("可用工具:")
+		fmt.Println(a.registry.Describe())
+		fmt.Println("用法: /tool <工具类型> <工具名称> [JSON参数]")
+		return
+	}
+
+	// 手动执行的工具同样要经过权限策略检查，需要确认时请用户确认
+	printToolCall(*call)
+	allowed, _, reason := a.authorize(ctx, call)
+	if !allowed {
+		a.lastToolResults = []toolResult{{call: *call, response: tools.ToolCallResponse{Error: reason}}}
+		printToolResults(a.lastToolResults, a.toolOutput)
+		return
+	}
+	response := tools.ExecuteTool(tools.WithPolicy(ctx, a.policy), *call, a.registry)
+	a.lastToolResults = []toolResult{{call: *call, response: response}}
+	printToolResults(a.lastToolResults, a.toolOutput)
+}
+
+// handleExpandCommand 处理 /expand 命令，完整显示最近一批工具调用的结果，可指定序号只显示其中一个
+func (a *AdvancedAgent) handleExpandCommand(args []string) {
+	if len(a.lastToolResults) == 0 {
+		fmt.Println("暂无工具调用结果")
+		return
+	}
+	if len(args) == 0 {
+		printToolResults(a.lastToolResults, TOOL_OUTPUT_FULL)
+		return
+	}
+
+	index, err := strconv.Atoi(args[0])
+	if err != nil || index < 1 || index > len(a.lastToolResults) {
+		fmt.Printf("用法: /expand [序号]，序号范围 1-%d\n", len(a.lastToolResults))
+		return
+	}
+	printToolResult(index, a.lastToolResults[index-1], TOOL_OUTPUT_FULL)
+}
+
+// handleVerboseCommand 处理 /verbose 命令，切换工具结果的显示方式
+func (a *AdvancedAgent) handleVerboseCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Printf("当前工具结果显示方式: %s（可选 quiet、normal、full）\n", a.toolOutput)
+		return
+	}
+
+	switch args[0] {
+	case TOOL_OUTPUT_QUIET, TOOL_OUTPUT_NORMAL, TOOL_OUTPUT_FULL:
+		a.toolOutput = args[0]
+		fmt.Printf("工具结果显示方式已切换为 %s\n", a.toolOutput)
+	default:
+		fmt.Println("用法: /verbose [quiet|normal|full]")
+	}
+}
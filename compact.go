@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"simple-agent/llm"
+	"simple-agent/logger"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// 上下文管理的默认配置
+const (
+	DEFAULT_CONTEXT_WINDOW        = 128000 // 模型上下文窗口（tokens）
+	DEFAULT_COMPACT_THRESHOLD     = 0.8    // 估算用量达到窗口的该比例时自动压缩
+	DEFAULT_KEEP_RECENT_TURNS     = 4      // 压缩时原样保留的最近回合数
+	DEFAULT_MAX_TOOL_OUTPUT_CHARS = 16000  // 单条工具结果写入历史时的最大字符数
+)
+
+// 压缩历史时使用的摘要提示词
+const COMPACT_SYSTEM_PROMPT = `你是一个对话摘要助手。请将下面的对话记录压缩成一份简洁的摘要，供后续对话继续使用。
+摘要需要保留：
+- 用户的目标、需求和明确提出的约束
+- 已经查看或修改过的文件、执行过的命令及其关键结果
+- 已经得出的结论、做出的决定和尚未完成的事项
+省略寒暄和重复内容，直接输出摘要正文。`
+
+// 摘要在对话历史中的前缀
+const COMPACT_SUMMARY_PREFIX = "[之前对话的摘要]\n"
+
+// estimateTokens 粗略估算消息占用的token数：ASCII字符约4个一个token，其他字符（如中文）约一个字符一个token
+func estimateTokens(messages []llm.Message) int {
+	total := 0
+	for _, message := range messages {
+		// 每条消息的角色和格式开销
+		total += 4
+		total += estimateTextTokens(message.Content)
+		for _, call := range message.ToolCalls {
+			total += estimateTextTokens(call.Function.Name) + estimateTextTokens(call.Function.Arguments)
+		}
+	}
+	return total
+}
+
+// estimateTextTokens 估算一段文本的token数
+func estimateTextTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// truncateToolOutput 截断过长的工具结果，保留开头和结尾
+func truncateToolOutput(content string, maxChars int) string {
+	if maxChars <= 0 || utf8.RuneCountInString(content) <= maxChars {
+		return content
+	}
+
+	runes := []rune(content)
+	head := maxChars * 2 / 3
+	tail := maxChars - head
+	omitted := len(runes) - head - tail
+	return fmt.Sprintf("%s\n...[输出过长，已省略中间 %d 个字符]...\n%s", string(runes[:head]), omitted, string(runes[len(runes)-tail:]))
+}
+
+// maybeCompact 估算的上下文用量达到阈值时自动压缩历史
+func (a *AdvancedAgent) maybeCompact(ctx context.Context) {
+	limit := int(float64(a.config.ContextWindow) * a.config.CompactThreshold)
+	estimated := estimateTokens(a.conversation)
+	if estimated < limit {
+		return
+	}
+
+	logger.Info("对话历史接近上下文窗口，开始自动压缩", zap.Int("估算tokens", estimated), zap.Int("阈值", limit))
+	fmt.Printf("%s对话历史约 %d tokens，接近上下文上限，正在压缩较早的对话...%s\n", colorDim, estimated, colorReset)
+	if _, err := a.compact(ctx); err != nil {
+		logger.Error("自动压缩对话历史失败", zap.Error(err))
+		printError(fmt.Sprintf("压缩对话历史失败: %v", err))
+	}
+}
+
+// compact 用模型总结较早的回合，保留系统提示词和最近的回合原文，返回是否进行了压缩
+func (a *AdvancedAgent) compact(ctx context.Context) (bool, error) {
+	// 只在用户消息处切分，保证工具调用和对应结果不会被拆开
+	var userIndexes []int
+	for i, message := range a.conversation {
+		if i > 0 && message.Role == "user" {
+			userIndexes = append(userIndexes, i)
+		}
+	}
+	if len(userIndexes) <= a.config.KeepRecentTurns {
+		return false, nil
+	}
+
+	cut := userIndexes[len(userIndexes)-a.config.KeepRecentTurns]
+	older := a.conversation[1:cut]
+
+	request := []llm.Message{
+		{Role: "system", Content: COMPACT_SYSTEM_PROMPT},
+		{Role: "user", Content: renderTranscript(older, a.config.MaxToolOutputChars)},
+	}
+	response, err := a.model.Complete(ctx, request, llm.Options{})
+	if err != nil {
+		return false, err
+	}
+	a.usage.record(response.Usage)
+
+	summary := strings.TrimSpace(response.Message.Content)
+	if summary == "" {
+		return false, fmt.Errorf("模型返回了空摘要")
+	}
+
+	compacted := []llm.Message{
+		a.conversation[0],
+		{Role: "user", Content: COMPACT_SUMMARY_PREFIX + summary},
+		{Role: "assistant", Content: "好的，我已了解之前的对话内容，会在此基础上继续。"},
+	}
+	compacted = append(compacted, a.conversation[cut:]...)
+
+	logger.Info("对话历史压缩完成",
+		zap.Int("压缩前消息数", len(a.conversation)), zap.Int("压缩后消息数", len(compacted)),
+		zap.Int("压缩前估算tokens", estimateTokens(a.conversation)), zap.Int("压缩后估算tokens", estimateTokens(compacted)))
+
+	a.replaceConversation(compacted)
+	return true, nil
+}
+
+// renderTranscript 将消息渲染为供摘要使用的纯文本记录，过长的工具结果会被截断
+func renderTranscript(messages []llm.Message, maxToolOutputChars int) string {
+	var transcript strings.Builder
+	transcript.WriteString("以下是需要压缩的对话记录：\n\n")
+
+	for _, message := range messages {
+		content := message.Content
+		if message.Role == "tool" {
+			content = truncateToolOutput(content, maxToolOutputChars/4)
+		}
+
+		transcript.WriteString(fmt.Sprintf("[%s]\n%s\n", message.Role, content))
+		for _, call := range message.ToolCalls {
+			transcript.WriteString(fmt.Sprintf("调用工具 %s，参数: %s\n", call.Function.Name, call.Function.Arguments))
+		}
+		transcript.WriteString("\n")
+	}
+
+	return transcript.String()
+}
@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIError 模型接口返回的错误
+type APIError struct {
+	StatusCode int           // HTTP状态码
+	Message    string        // 错误信息
+	Type       string        // 错误类型
+	Code       string        // 错误代码
+	RetryAfter time.Duration // 服务端通过 Retry-After 建议的等待时间
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API返回错误状态码: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("API错误: %s (状态码: %d, 类型: %s, 代码: %s)", e.Message, e.StatusCode, e.Type, e.Code)
+}
+
+// 不可重试的错误代码：内容安全拦截、账户余额不足等，重试也不会成功
+var permanentErrorCodes = map[string]bool{
+	"1113":                     true, // 智谱：账户余额不足
+	"1301":                     true, // 智谱：内容包含不安全或敏感内容
+	"content_filter":           true, // OpenAI：内容过滤
+	"content_policy_violation": true, // OpenAI：违反内容政策
+	"insufficient_quota":       true, // OpenAI：额度不足
+	"invalid_api_key":          true, // OpenAI：密钥无效
+}
+
+// Retryable 判断错误是否值得重试：限流、超时和服务端错误可以重试，鉴权失败和内容拦截不能重试
+func (e *APIError) Retryable() bool {
+	if permanentErrorCodes[e.Code] {
+		return false
+	}
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode == http.StatusRequestTimeout,
+		e.StatusCode >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+// apiErrorBody 接口返回的 error 字段，不同服务的 code 可能是字符串也可能是数字
+type apiErrorBody struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Code    json.RawMessage `json:"code"`
+}
+
+// toAPIError 转换为 APIError
+func (b *apiErrorBody) toAPIError(statusCode int) *APIError {
+	code := strings.Trim(strings.TrimSpace(string(b.Code)), `"`)
+	if code == "null" {
+		code = ""
+	}
+	return &APIError{StatusCode: statusCode, Message: b.Message, Type: b.Type, Code: code}
+}
+
+// parseErrorResponse 从错误响应体中解析 APIError，解析失败时仅保留状态码和原始内容
+func parseErrorResponse(statusCode int, rawBody []byte, retryAfter string) *APIError {
+	var body struct {
+		Error apiErrorBody `json:"error"`
+	}
+	apiErr := &APIError{StatusCode: statusCode, Message: strings.TrimSpace(string(rawBody))}
+	if err := json.Unmarshal(rawBody, &body); err == nil && body.Error.Message != "" {
+		apiErr = body.Error.toAPIError(statusCode)
+	}
+	apiErr.RetryAfter = parseRetryAfter(retryAfter)
+	return apiErr
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// IsRetryable 判断一次请求错误是否可以重试，网络错误可以重试，上下文取消不重试
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+
+	// 响应解析失败说明服务端返回了异常内容，重试意义不大
+	var parseErr *ResponseParseError
+	if errors.As(err, &parseErr) {
+		return false
+	}
+
+	// 其余都是连接重置、超时等网络错误
+	return true
+}
+
+// ResponseParseError 响应内容无法解析
+type ResponseParseError struct {
+	Err error  // 解析错误
+	Raw string // 原始响应内容
+}
+
+// Error 实现 error 接口
+func (e *ResponseParseError) Error() string {
+	return fmt.Sprintf("解析API响应失败: %v, 原始响应: %s", e.Err, e.Raw)
+}
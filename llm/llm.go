@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Message 表示与模型交互的消息结构
+type Message struct {
+	Role             string     `json:"role"`                        // 消息角色：system, user, assistant, tool
+	Content          string     `json:"content"`                     // 消息内容
+	ReasoningContent string     `json:"reasoning_content,omitempty"` // 思考模式下模型的推理过程，仅本地保存，不回传给模型
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`        // 模型请求的原生工具调用
+	ToolCallID       string     `json:"tool_call_id,omitempty"`      // tool 消息对应的工具调用ID
+}
+
+// requestMessages 返回发送给模型的消息副本，去掉推理过程，部分服务收到 reasoning_content 会报错；
+// 文本模式的工具结果没有对应的调用ID，接口不接受这样的 tool 消息，改为 user 消息发送
+func requestMessages(messages []Message) []Message {
+	result := make([]Message, len(messages))
+	for i, message := range messages {
+		message.ReasoningContent = ""
+		if message.Role == "tool" && message.ToolCallID == "" {
+			message.Role = "user"
+		}
+		result[i] = message
+	}
+	return result
+}
+
+// ToolCall 模型返回的原生函数调用
+type ToolCall struct {
+	ID       string       `json:"id"`       // 调用ID，回复工具结果时原样带回
+	Type     string       `json:"type"`     // 调用类型，目前只有 function
+	Function FunctionCall `json:"function"` // 函数调用内容
+}
+
+// FunctionCall 函数调用的名称和参数
+type FunctionCall struct {
+	Name      string `json:"name"`      // 函数名
+	Arguments string `json:"arguments"` // JSON 编码的参数
+}
+
+// ToolSpec 发送给模型的工具定义
+type ToolSpec struct {
+	Name        string      // 函数名
+	Description string      // 函数说明
+	Parameters  interface{} // 参数的 JSON Schema
+}
+
+// Options 单次补全请求的可选参数
+type Options struct {
+	Model   string      // 覆盖客户端默认模型，为空时使用默认模型
+	OnDelta func(Delta) // 流式回调，不为空时以 SSE 流式方式请求，每收到一段增量调用一次
+	Tools   []ToolSpec  // 可供模型原生调用的工具，为空时不发送 tools 字段
+	// ToolChoice 工具选择策略，为空时由模型自动决定（auto），none 表示本次请求禁止调用工具
+	ToolChoice string
+}
+
+// Delta 流式输出中的一段增量内容
+type Delta struct {
+	Content          string // 新增的回复文本
+	ReasoningContent string // 新增的推理过程文本
+}
+
+// Response 模型补全结果
+type Response struct {
+	Message Message // 模型回复的消息
+	Usage   Usage   // 本次请求的token用量，服务未返回时为零值
+}
+
+// Usage token用量统计
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`     // 输入token数
+	CompletionTokens int `json:"completion_tokens"` // 输出token数
+	TotalTokens      int `json:"total_tokens"`      // 总token数
+}
+
+// Add 累加另一份用量
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// ChatModel 聊天模型提供方接口，每个后端实现一个
+type ChatModel interface {
+	// Complete 发送对话历史并返回模型回复
+	Complete(ctx context.Context, messages []Message, opts Options) (*Response, error)
+}
+
+// 定义模型提供方常量
+const (
+	PROVIDER_ZHIPU  = "zhipu"  // 智谱AI
+	PROVIDER_OPENAI = "openai" // OpenAI 兼容接口
+)
+
+// Config 模型提供方配置
+type Config struct {
+	Provider string // 模型提供方：zhipu, openai
+	APIKey   string // API密钥
+	BaseURL  string // 接口基础地址，为空时使用提供方默认地址
+	Model    string // 模型名称，为空时使用提供方默认模型
+	Thinking bool   // 是否启用思考模式（目前仅智谱支持开关）
+}
+
+// DefaultModel 返回模型提供方的默认模型
+func DefaultModel(provider string) string {
+	if provider == PROVIDER_OPENAI {
+		return OPENAI_DEFAULT_MODEL
+	}
+	return GLM_DEFAULT_MODEL
+}
+
+// NewChatModel 根据配置创建对应的模型客户端
+func NewChatModel(config Config) (ChatModel, error) {
+	switch config.Provider {
+	case PROVIDER_ZHIPU, "":
+		client := NewZhipuClient(config.APIKey, config.BaseURL, config.Model)
+		client.Thinking = config.Thinking
+		return client, nil
+
+	case PROVIDER_OPENAI:
+		return NewOpenAIClient(config.APIKey, config.BaseURL, config.Model), nil
+
+	default:
+		return nil, fmt.Errorf("未知的模型提供方: %s", config.Provider)
+	}
+}
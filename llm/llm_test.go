@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestMessagesSendsTextModeToolResultsAsUser(t *testing.T) {
+	var received struct {
+		Messages []Message `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("解析请求失败: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(successBody))
+	}))
+	t.Cleanup(server.Close)
+
+	messages := []Message{
+		{Role: "user", Content: "列出目录"},
+		{Role: "assistant", Content: `{"type":"file_operation","name":"list","args":{}}`, ReasoningContent: "先看看目录"},
+		{Role: "tool", Content: "工具执行结果: ..."},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Type: "function", Function: FunctionCall{Name: "file_operation__list", Arguments: "{}"}}}},
+		{Role: "tool", Content: "a.go", ToolCallID: "call_1"},
+	}
+	client := NewOpenAIClient("test-key", server.URL, "test-model")
+	if _, err := client.Complete(context.Background(), messages, Options{}); err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+
+	wantRoles := []string{"user", "assistant", "user", "assistant", "tool"}
+	if len(received.Messages) != len(wantRoles) {
+		t.Fatalf("收到 %d 条消息，期望 %d 条", len(received.Messages), len(wantRoles))
+	}
+	for i, role := range wantRoles {
+		if received.Messages[i].Role != role {
+			t.Errorf("第 %d 条消息的角色为 %q，期望 %q", i+1, received.Messages[i].Role, role)
+		}
+	}
+	if received.Messages[1].ReasoningContent != "" {
+		t.Errorf("请求中带有推理过程 %q", received.Messages[1].ReasoningContent)
+	}
+	if received.Messages[4].ToolCallID != "call_1" {
+		t.Errorf("原生工具结果的调用ID为 %q", received.Messages[4].ToolCallID)
+	}
+	if messages[2].Role != "tool" {
+		t.Errorf("对话历史中的消息被修改为 %q", messages[2].Role)
+	}
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"simple-agent/logger"
+
+	"github.com/imroc/req/v3"
+	"go.uber.org/zap"
+)
+
+// OPENAI_BASE_URL OpenAI 官方接口基础地址
+const OPENAI_BASE_URL = "https://api.openai.com/v1"
+
+// OPENAI_DEFAULT_MODEL OpenAI 兼容接口的默认模型
+const OPENAI_DEFAULT_MODEL = "gpt-4o-mini"
+
+// chatClient 封装 OpenAI 兼容的 /chat/completions 请求
+type chatClient struct {
+	name    string      // 提供方名称，用于日志
+	apiKey  string      // API密钥
+	baseURL string      // 接口基础地址
+	model   string      // 默认模型
+	client  *req.Client // HTTP客户端
+}
+
+// newChatClient 创建一个 chat completions 客户端
+func newChatClient(name, apiKey, baseURL, model string) chatClient {
+	return chatClient{
+		name:    name,
+		apiKey:  apiKey,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		client:  req.C(),
+	}
+}
+
+// modelName 返回本次请求使用的模型
+func (c *chatClient) modelName(opts Options) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return c.model
+}
+
+// complete 发送请求体到 /chat/completions 并解析回复，opts.OnDelta 不为空时使用流式输出
+func (c *chatClient) complete(ctx context.Context, requestBody map[string]interface{}, opts Options) (*Response, error) {
+	url := c.baseURL + "/chat/completions"
+	stream := opts.OnDelta != nil
+	requestBody["stream"] = stream
+	if len(opts.Tools) > 0 {
+		requestBody["tools"] = toolDefinitions(opts.Tools)
+		requestBody["tool_choice"] = "auto"
+		if opts.ToolChoice != "" {
+			requestBody["tool_choice"] = opts.ToolChoice
+		}
+	}
+
+	request := c.client.R().
+		SetContext(ctx).
+		SetHeader("Authorization", "Bearer "+c.apiKey).
+		SetHeader("Content-Type", "application/json").
+		SetBody(requestBody)
+	if stream {
+		// 流式响应需要边读边解析，不能让客户端自动读完响应体
+		request.DisableAutoReadResponse()
+	}
+
+	logger.Debug("正在发送请求到模型接口", zap.String("提供方", c.name), zap.String("地址", url), zap.Bool("流式", stream))
+	resp, err := request.Post(url)
+	if err != nil {
+		logger.Error("API请求错误", zap.Error(err))
+		return nil, err
+	}
+	if stream {
+		defer resp.Body.Close()
+	}
+
+	// 打印响应状态码
+	logger.Debug("API响应状态码", zap.Int("状态码", resp.StatusCode))
+
+	// 检查HTTP状态码
+	if resp.StatusCode != 200 {
+		rawBody, _ := resp.ToBytes()
+		logger.Error("API返回错误状态码", zap.Int("状态码", resp.StatusCode), zap.String("错误响应", string(rawBody)))
+		return nil, parseErrorResponse(resp.StatusCode, rawBody, resp.GetHeader("Retry-After"))
+	}
+
+	if stream {
+		return readStream(resp.Body, opts.OnDelta)
+	}
+
+	// 获取原始响应内容用于调试
+	rawBody, _ := resp.ToBytes()
+
+	// 解析响应
+	var response struct {
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+		Usage Usage        `json:"usage"`
+		Error apiErrorBody `json:"error"`
+	}
+
+	err = resp.UnmarshalJson(&response)
+	if err != nil {
+		logger.Error("解析API响应失败", zap.Error(err), zap.String("原始响应", string(rawBody)))
+		return nil, &ResponseParseError{Err: err, Raw: string(rawBody)}
+	}
+
+	// 检查是否有API错误
+	if response.Error.Message != "" {
+		apiErr := response.Error.toAPIError(resp.StatusCode)
+		logger.Error("API错误", zap.String("消息", apiErr.Message), zap.String("类型", apiErr.Type), zap.String("代码", apiErr.Code))
+		return nil, apiErr
+	}
+
+	// 检查是否有有效回复
+	if len(response.Choices) == 0 {
+		logger.Error("API返回了空的choices数组")
+		return nil, fmt.Errorf("API返回了空回复")
+	}
+
+	// 打印模型返回的原始内容用于调试
+	logger.Debug("模型返回的原始内容", zap.String("内容", response.Choices[0].Message.Content), zap.Int("工具调用数量", len(response.Choices[0].Message.ToolCalls)))
+
+	return &Response{Message: response.Choices[0].Message, Usage: response.Usage}, nil
+}
+
+// toolDefinitions 将工具定义转换为请求体中的 tools 字段
+func toolDefinitions(specs []ToolSpec) []map[string]interface{} {
+	definitions := make([]map[string]interface{}, 0, len(specs))
+	for _, spec := range specs {
+		definitions = append(definitions, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        spec.Name,
+				"description": spec.Description,
+				"parameters":  spec.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// OpenAIClient 通用的 OpenAI 兼容 /v1/chat/completions 客户端
+type OpenAIClient struct {
+	chatClient
+}
+
+// NewOpenAIClient 创建 OpenAI 兼容客户端，baseURL 形如 https://api.openai.com/v1
+func NewOpenAIClient(apiKey, baseURL, model string) *OpenAIClient {
+	if baseURL == "" {
+		baseURL = OPENAI_BASE_URL
+	}
+	if model == "" {
+		model = OPENAI_DEFAULT_MODEL
+	}
+	return &OpenAIClient{chatClient: newChatClient(PROVIDER_OPENAI, apiKey, baseURL, model)}
+}
+
+// Complete 实现 ChatModel 接口
+func (c *OpenAIClient) Complete(ctx context.Context, messages []Message, opts Options) (*Response, error) {
+	requestBody := map[string]interface{}{
+		"model":    c.modelName(opts),
+		"messages": requestMessages(messages),
+	}
+	if opts.OnDelta != nil {
+		// OpenAI 流式响应默认不返回用量，需要显式开启
+		requestBody["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	return c.complete(ctx, requestBody, opts)
+}
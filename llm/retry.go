@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"simple-agent/logger"
+
+	"go.uber.org/zap"
+)
+
+// RetryPolicy 模型请求的重试策略
+type RetryPolicy struct {
+	MaxAttempts int           // 最大尝试次数（包含首次请求），小于等于1时不重试
+	BaseDelay   time.Duration // 首次重试前的基础等待时间，之后按指数增长
+	MaxDelay    time.Duration // 单次等待的上限
+}
+
+// DefaultRetryPolicy 返回默认重试策略：最多尝试4次，等待时间从1秒指数增长到30秒
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Second,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// backoff 计算第 attempt 次失败后的等待时间，带抖动以避免多个请求同时重试
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	// 在 [delay/2, delay] 区间内随机取值
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryingModel 为 ChatModel 增加失败重试
+type retryingModel struct {
+	model  ChatModel
+	policy RetryPolicy
+}
+
+// WithRetry 包装模型客户端，按策略重试可恢复的错误
+func WithRetry(model ChatModel, policy RetryPolicy) ChatModel {
+	if policy.MaxAttempts <= 1 {
+		return model
+	}
+	return &retryingModel{model: model, policy: policy}
+}
+
+// Complete 实现 ChatModel 接口
+func (m *retryingModel) Complete(ctx context.Context, messages []Message, opts Options) (*Response, error) {
+	// 流式输出一旦开始就不能重试，否则终端上会重复打印
+	streamed := false
+	if onDelta := opts.OnDelta; onDelta != nil {
+		opts.OnDelta = func(delta Delta) {
+			streamed = true
+			onDelta(delta)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		response, err := m.model.Complete(ctx, messages, opts)
+		if err == nil {
+			return response, nil
+		}
+
+		if streamed || !IsRetryable(err) {
+			return nil, err
+		}
+		if attempt >= m.policy.MaxAttempts {
+			return nil, fmt.Errorf("已重试 %d 次仍然失败: %w", attempt-1, err)
+		}
+
+		// 优先使用服务端建议的等待时间，超过单次等待上限时不再等待，直接返回错误
+		delay := m.policy.backoff(attempt)
+		if apiErr, ok := err.(*APIError); ok && apiErr.RetryAfter > delay {
+			if m.policy.MaxDelay > 0 && apiErr.RetryAfter > m.policy.MaxDelay {
+				logger.Warn("服务端要求的等待时间过长，不再重试", zap.Duration("等待", apiErr.RetryAfter), zap.Duration("上限", m.policy.MaxDelay))
+				return nil, fmt.Errorf("服务端要求 %v 后再重试，超过了单次等待上限 %v: %w", apiErr.RetryAfter, m.policy.MaxDelay, err)
+			}
+			delay = apiErr.RetryAfter
+		}
+
+		logger.Warn("模型请求失败，准备重试", zap.Error(err), zap.Int("第几次", attempt), zap.Duration("等待", delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
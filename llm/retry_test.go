@@ -0,0 +1,181 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedReply 测试服务对一次请求的回复
+type scriptedReply struct {
+	status     int
+	retryAfter string
+	body       string
+}
+
+// successBody 成功回复的响应体
+const successBody = `{"choices":[{"message":{"role":"assistant","content":"好的"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`
+
+// scriptedServer 按顺序返回 replies 的测试服务，超出后重复最后一个，返回服务和已收到的请求数
+func scriptedServer(t *testing.T, replies []scriptedReply) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := int(atomic.AddInt32(&requests, 1)) - 1
+		if index >= len(replies) {
+			index = len(replies) - 1
+		}
+		reply := replies[index]
+		if reply.retryAfter != "" {
+			w.Header().Set("Retry-After", reply.retryAfter)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(reply.status)
+		w.Write([]byte(reply.body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// retryClient 创建请求测试服务、等待时间很短的重试客户端
+func retryClient(server *httptest.Server) ChatModel {
+	return WithRetry(NewOpenAIClient("test-key", server.URL, "test-model"), RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Second,
+	})
+}
+
+// completeOnce 发送一次非流式请求
+func completeOnce(ctx context.Context, model ChatModel) (*Response, error) {
+	return model.Complete(ctx, []Message{{Role: "user", Content: "你好"}}, Options{})
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	server, requests := scriptedServer(t, []scriptedReply{
+		{status: http.StatusTooManyRequests, retryAfter: "1", body: `{"error":{"message":"请求过于频繁","code":"rate_limit"}}`},
+		{status: http.StatusOK, body: successBody},
+	})
+
+	start := time.Now()
+	response, err := completeOnce(context.Background(), retryClient(server))
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if response.Message.Content != "好的" {
+		t.Errorf("回复为 %+v", response.Message)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("请求了 %d 次，期望 2 次", got)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("只等待了 %v，没有遵守 Retry-After", elapsed)
+	}
+}
+
+func TestRetryFailsFastWhenRetryAfterExceedsMaxDelay(t *testing.T) {
+	server, requests := scriptedServer(t, []scriptedReply{
+		{status: http.StatusTooManyRequests, retryAfter: "3600", body: `{"error":{"message":"请求过于频繁"}}`},
+		{status: http.StatusOK, body: successBody},
+	})
+
+	start := time.Now()
+	_, err := completeOnce(context.Background(), retryClient(server))
+	if err == nil || !strings.Contains(err.Error(), "超过了单次等待上限") {
+		t.Fatalf("错误为 %v，期望说明等待时间超过上限", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("错误没有包含原始的 429 错误: %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("请求了 %d 次，期望 1 次", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("等待了 %v，期望立即返回", elapsed)
+	}
+}
+
+func TestRetryServerErrorThenSuccess(t *testing.T) {
+	server, requests := scriptedServer(t, []scriptedReply{
+		{status: http.StatusServiceUnavailable, body: "Service Unavailable"},
+		{status: http.StatusOK, body: successBody},
+	})
+
+	response, err := completeOnce(context.Background(), retryClient(server))
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	if response.Usage.TotalTokens != 2 {
+		t.Errorf("用量为 %+v", response.Usage)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("请求了 %d 次，期望 2 次", got)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	server, requests := scriptedServer(t, []scriptedReply{
+		{status: http.StatusBadGateway, body: "Bad Gateway"},
+	})
+
+	_, err := completeOnce(context.Background(), retryClient(server))
+	if err == nil || !strings.Contains(err.Error(), "已重试 2 次仍然失败") {
+		t.Fatalf("错误为 %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 3 {
+		t.Errorf("请求了 %d 次，期望 3 次", got)
+	}
+}
+
+func TestRetrySkipsPermanentErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply scriptedReply
+	}{
+		{"鉴权失败", scriptedReply{status: http.StatusUnauthorized, body: `{"error":{"message":"密钥无效","code":"invalid_api_key"}}`}},
+		{"内容过滤", scriptedReply{status: http.StatusInternalServerError, body: `{"error":{"message":"内容被拦截","code":"content_filter"}}`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := scriptedServer(t, []scriptedReply{test.reply, {status: http.StatusOK, body: successBody}})
+
+			_, err := completeOnce(context.Background(), retryClient(server))
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != test.reply.status {
+				t.Fatalf("错误为 %v，期望状态码 %d 的 APIError", err, test.reply.status)
+			}
+			if got := atomic.LoadInt32(requests); got != 1 {
+				t.Errorf("请求了 %d 次，期望不重试", got)
+			}
+		})
+	}
+}
+
+func TestRetryCancelledWhileWaiting(t *testing.T) {
+	server, requests := scriptedServer(t, []scriptedReply{
+		{status: http.StatusTooManyRequests, retryAfter: "3", body: `{"error":{"message":"请求过于频繁"}}`},
+		{status: http.StatusOK, body: successBody},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := completeOnce(ctx, retryClient(server))
+	if err != context.Canceled {
+		t.Fatalf("错误为 %v，期望 context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("取消后等待了 %v 才返回", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("请求了 %d 次，期望 1 次", got)
+	}
+}
@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"simple-agent/logger"
+
+	"go.uber.org/zap"
+)
+
+// streamChunk SSE 流中单个 data 事件的结构
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Role             string `json:"role"`
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage        `json:"usage"`
+	Error *apiErrorBody `json:"error"`
+}
+
+// readStream 逐行读取 SSE 响应，每段增量回调 onDelta，并拼装出完整的回复消息
+func readStream(body io.Reader, onDelta func(Delta)) (*Response, error) {
+	message := Message{Role: "assistant"}
+	var content, reasoning strings.Builder
+
+	// 用量通常在最后一个事件中返回
+	var usage Usage
+
+	// 工具调用按 index 分片下发，需要逐段拼接参数
+	var toolCalls []ToolCall
+
+	scanner := bufio.NewScanner(body)
+	// 单个事件可能较长，放宽默认的 64KB 行长度限制
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	done := false
+	for !done && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// 忽略空行、注释行和非 data 字段
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logger.Error("解析流式响应失败", zap.Error(err), zap.String("原始数据", data))
+			return nil, &ResponseParseError{Err: err, Raw: data}
+		}
+
+		if chunk.Error != nil && chunk.Error.Message != "" {
+			apiErr := chunk.Error.toAPIError(200)
+			logger.Error("API错误", zap.String("消息", apiErr.Message), zap.String("类型", apiErr.Type), zap.String("代码", apiErr.Code))
+			return nil, apiErr
+		}
+
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Role != "" {
+				message.Role = choice.Delta.Role
+			}
+			if choice.Delta.ReasoningContent != "" {
+				reasoning.WriteString(choice.Delta.ReasoningContent)
+				onDelta(Delta{ReasoningContent: choice.Delta.ReasoningContent})
+			}
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				onDelta(Delta{Content: choice.Delta.Content})
+			}
+			for _, part := range choice.Delta.ToolCalls {
+				for len(toolCalls) <= part.Index {
+					toolCalls = append(toolCalls, ToolCall{Type: "function"})
+				}
+				call := &toolCalls[part.Index]
+				if part.ID != "" {
+					call.ID = part.ID
+				}
+				if part.Type != "" {
+					call.Type = part.Type
+				}
+				call.Function.Name += part.Function.Name
+				call.Function.Arguments += part.Function.Arguments
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		logger.Error("读取流式响应失败", zap.Error(err))
+		return nil, fmt.Errorf("读取流式响应失败: %v", err)
+	}
+
+	message.Content = content.String()
+	message.ReasoningContent = reasoning.String()
+	message.ToolCalls = toolCalls
+
+	// 打印模型返回的原始内容用于调试
+	logger.Debug("模型返回的原始内容", zap.String("内容", message.Content), zap.Int("工具调用数量", len(message.ToolCalls)))
+
+	return &Response{Message: message, Usage: usage}, nil
+}
@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sseServer 启动一个按顺序返回 events 的本地 SSE 服务，每个事件单独刷新
+func sseServer(t *testing.T, events []string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\n\n", event)
+			flusher.Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// streamComplete 通过 OpenAI 兼容客户端请求 SSE 服务，返回回复和收到的增量
+func streamComplete(t *testing.T, events []string) (*Response, []Delta, error) {
+	t.Helper()
+	server := sseServer(t, events)
+	client := NewOpenAIClient("test-key", server.URL, "test-model")
+
+	var deltas []Delta
+	response, err := client.Complete(context.Background(), []Message{{Role: "user", Content: "你好"}}, Options{
+		OnDelta: func(delta Delta) { deltas = append(deltas, delta) },
+	})
+	return response, deltas, err
+}
+
+func TestReadStreamAssemblesDeltas(t *testing.T) {
+	events := []string{
+		": keep-alive",
+		`data: {"choices":[{"delta":{"role":"assistant","reasoning_content":"先想"}}]}`,
+		`data: {"choices":[{"delta":{"reasoning_content":"一想"}}]}`,
+		`data: {"choices":[{"delta":{"content":"你"}}]}`,
+		`data:{"choices":[{"delta":{"content":"好"}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"file_operation__read","arguments":""}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"file_operation__list","arguments":"{\"path\":"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.go\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\".\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":12,"completion_tokens":34,"total_tokens":46}}`,
+		"data: [DONE]",
+		`data: {"choices":[{"delta":{"content":"DONE 之后的内容应被忽略"}}]}`,
+	}
+
+	response, deltas, err := streamComplete(t, events)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+
+	message := response.Message
+	if message.Role != "assistant" || message.Content != "你好" || message.ReasoningContent != "先想一想" {
+		t.Errorf("消息为 %+v", message)
+	}
+
+	want := []Delta{{ReasoningContent: "先想"}, {ReasoningContent: "一想"}, {Content: "你"}, {Content: "好"}}
+	if len(deltas) != len(want) {
+		t.Fatalf("收到增量 %+v，期望 %+v", deltas, want)
+	}
+	for i := range want {
+		if deltas[i] != want[i] {
+			t.Errorf("第 %d 段增量为 %+v，期望 %+v", i+1, deltas[i], want[i])
+		}
+	}
+
+	calls := message.ToolCalls
+	if len(calls) != 2 {
+		t.Fatalf("工具调用为 %+v，期望 2 个", calls)
+	}
+	if calls[0].ID != "call_1" || calls[0].Type != "function" || calls[0].Function.Name != "file_operation__read" || calls[0].Function.Arguments != `{"path":"a.go"}` {
+		t.Errorf("第 1 个工具调用为 %+v", calls[0])
+	}
+	if calls[1].ID != "call_2" || calls[1].Type != "function" || calls[1].Function.Name != "file_operation__list" || calls[1].Function.Arguments != `{"path":"."}` {
+		t.Errorf("第 2 个工具调用为 %+v", calls[1])
+	}
+
+	if response.Usage != (Usage{PromptTokens: 12, CompletionTokens: 34, TotalTokens: 46}) {
+		t.Errorf("用量为 %+v", response.Usage)
+	}
+}
+
+func TestReadStreamErrorEvent(t *testing.T) {
+	events := []string{
+		`data: {"choices":[{"delta":{"content":"部分"}}]}`,
+		`data: {"error":{"message":"服务繁忙","type":"server_error","code":"overloaded"}}`,
+		`data: {"choices":[{"delta":{"content":"不应读取"}}]}`,
+		"data: [DONE]",
+	}
+
+	response, deltas, err := streamComplete(t, events)
+	if response != nil {
+		t.Errorf("出错时返回了回复 %+v", response)
+	}
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("错误为 %v (%T)，期望 *APIError", err, err)
+	}
+	if apiErr.Message != "服务繁忙" || apiErr.Type != "server_error" || apiErr.Code != "overloaded" {
+		t.Errorf("错误为 %+v", apiErr)
+	}
+	if len(deltas) != 1 || deltas[0].Content != "部分" {
+		t.Errorf("出错前收到的增量为 %+v", deltas)
+	}
+}
+
+func TestReadStreamMalformedEvent(t *testing.T) {
+	_, _, err := streamComplete(t, []string{`data: {"choices":[`})
+	parseErr, ok := err.(*ResponseParseError)
+	if !ok {
+		t.Fatalf("错误为 %v (%T)，期望 *ResponseParseError", err, err)
+	}
+	if !strings.Contains(parseErr.Raw, `{"choices":[`) {
+		t.Errorf("原始数据为 %q", parseErr.Raw)
+	}
+}
@@ -0,0 +1,43 @@
+package llm
+
+import "context"
+
+// GLM_BASE_URL 智谱AI接口基础地址
+const GLM_BASE_URL = "https://open.bigmodel.cn/api/paas/v4"
+
+// GLM_DEFAULT_MODEL 智谱AI默认模型
+const GLM_DEFAULT_MODEL = "glm-4.5-flash"
+
+// ZhipuClient 智谱AI GLM 模型客户端
+type ZhipuClient struct {
+	chatClient
+	Thinking bool // 是否启用动态思考模式，默认启用
+}
+
+// NewZhipuClient 创建智谱AI客户端，baseURL 和 model 为空时使用默认值
+func NewZhipuClient(apiKey, baseURL, model string) *ZhipuClient {
+	if baseURL == "" {
+		baseURL = GLM_BASE_URL
+	}
+	if model == "" {
+		model = GLM_DEFAULT_MODEL
+	}
+	return &ZhipuClient{chatClient: newChatClient(PROVIDER_ZHIPU, apiKey, baseURL, model), Thinking: true}
+}
+
+// Complete 实现 ChatModel 接口
+func (c *ZhipuClient) Complete(ctx context.Context, messages []Message, opts Options) (*Response, error) {
+	thinking := "enabled" // 启用动态思考模式
+	if !c.Thinking {
+		thinking = "disabled"
+	}
+
+	requestBody := map[string]interface{}{
+		"model":    c.modelName(opts),
+		"messages": requestMessages(messages),
+		"thinking": map[string]string{
+			"type": thinking,
+		},
+	}
+	return c.complete(ctx, requestBody, opts)
+}
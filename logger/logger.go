@@ -11,6 +11,9 @@ import (
 // 全局日志变量
 var Logger *zap.Logger
 
+// fileLogger 只写入日志文件的日志，用于不应打断交互界面的信息
+var fileLogger *zap.Logger
+
 // Init 初始化日志系统
 func Init() {
     // 确保 logs 目录存在
@@ -53,6 +56,7 @@ func Init() {
     // 组合多个核心
     teeCore := zapcore.NewTee(fileCore, consoleCore)
     Logger = zap.New(teeCore, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+    fileLogger = zap.New(fileCore, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
 
     Logger.Debug("日志系统初始化完成")
 }
@@ -71,6 +75,13 @@ func Info(msg string, fields ...zap.Field) {
     }
 }
 
+// InfoToFile 记录只写入日志文件、不输出到控制台的信息日志
+func InfoToFile(msg string, fields ...zap.Field) {
+    if fileLogger != nil {
+        fileLogger.Info(msg, fields...)
+    }
+}
+
 // Error 记录错误日志
 func Error(msg string, fields ...zap.Field) {
     if Logger != nil {
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"simple-agent/tools"
+)
+
+// 工具循环的限制
+const (
+	DEFAULT_MAX_TOOL_ROUNDS   = 20 // 单轮对话中工具调用的默认最大轮数
+	MAX_REPEATED_TOOL_CALLS   = 3  // 单轮对话中同一调用（类型、名称和参数都相同）的最大次数
+	MAX_TOOL_CALL_CORRECTIONS = 3  // 单轮对话中工具调用无法解析或调用无效工具时，允许模型修正的最大次数
+)
+
+// 回复中的工具调用无法解析时发给模型的提示，%s 为具体问题
+const TOOL_CALL_CORRECTION_PROMPT = `[系统提示] 上一条回复中的工具调用无法解析，没有执行任何工具。
+%s
+
+请修正后重新输出完整的工具调用。工具调用是一个 JSON 数组，每个调用只能包含 type、name、args、thought 四个字段，args 必须是对象：
+[{"type": "工具类型", "name": "工具名称", "args": {"参数名": "参数值"}, "thought": "调用原因"}]
+如果不需要调用工具，请直接回答，不要输出类似工具调用的 JSON。`
+
+// 工具循环被强制停止时发给模型的提示，%s 为停止原因
+const TOOL_LOOP_STOP_PROMPT = "[系统提示] %s。请不要再调用任何工具，根据目前已经获得的信息直接给出最终回答；如果信息不足以完成任务，请说明进展和还缺少什么。"
+
+// toolLoopGuard 限制单轮对话中的工具调用轮数，并检测重复的相同调用
+type toolLoopGuard struct {
+	maxRounds int            // 最大轮数
+	rounds    int            // 已执行的轮数
+	calls     map[string]int // 每个调用签名已执行的次数
+}
+
+// newToolLoopGuard 创建工具循环限制，maxRounds 不大于0时使用默认值
+func newToolLoopGuard(maxRounds int) *toolLoopGuard {
+	if maxRounds <= 0 {
+		maxRounds = DEFAULT_MAX_TOOL_ROUNDS
+	}
+	return &toolLoopGuard{maxRounds: maxRounds, calls: make(map[string]int)}
+}
+
+// check 在执行一轮工具调用前检查是否应当停止，需要停止时返回原因，否则记录本轮调用
+func (g *toolLoopGuard) check(calls []tools.Tool) string {
+	if g.rounds >= g.maxRounds {
+		return fmt.Sprintf("本轮对话已连续调用工具 %d 轮，达到上限", g.rounds)
+	}
+
+	signatures := make([]string, len(calls))
+	pending := make(map[string]int, len(calls))
+	for i, call := range calls {
+		signatures[i] = toolCallSignature(call)
+		pending[signatures[i]]++
+		if count := g.calls[signatures[i]] + pending[signatures[i]]; count > MAX_REPEATED_TOOL_CALLS {
+			return fmt.Sprintf("工具调用 %s 在本轮对话中已是第 %d 次发起，重复调用不会得到新的结果", signatures[i], count)
+		}
+	}
+
+	g.rounds++
+	for _, signature := range signatures {
+		g.calls[signature]++
+	}
+	return ""
+}
+
+// toolCallSignature 生成工具调用的签名，参数按键排序序列化，相同调用的签名一致
+func toolCallSignature(call tools.Tool) string {
+	args, _ := json.Marshal(call.Args)
+	return fmt.Sprintf("%s.%s %s", call.Type, call.Name, args)
+}
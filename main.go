@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"simple-agent/llm"
 	"simple-agent/logger"
+	"simple-agent/session"
 	"simple-agent/tools"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/chzyer/readline"
 	"go.uber.org/zap"
@@ -18,7 +24,35 @@ import (
 // 全局readline实例
 var rl *readline.Instance
 
+// 全局 Ctrl+C 记录，readline 和信号处理共用
+var interrupts interruptGuard
+
+// doubleInterruptWindow 连按两次 Ctrl+C 退出程序的时间窗口
+const doubleInterruptWindow = 2 * time.Second
+
+// interruptGuard 记录最近一次 Ctrl+C 的时间，用于判断是否连按两次
+type interruptGuard struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// press 记录一次 Ctrl+C，返回距上次是否在退出窗口内
+func (g *interruptGuard) press() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	exit := !g.last.IsZero() && now.Sub(g.last) < doubleInterruptWindow
+	g.last = now
+	return exit
+}
+
 func main() {
+	// 解析命令行参数
+	resumeID := flag.String("resume", "", "恢复指定ID的会话")
+	continueLatest := flag.Bool("continue", false, "继续最近一次会话")
+	flag.Parse()
+
 	// 初始化日志系统
 	logger.Init()
 	defer logger.Sync()
@@ -27,18 +61,6 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// 设置信号处理，以便优雅地退出
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-sigCh
-		logger.Info("接收到退出信号，正在退出...")
-		if rl != nil {
-			rl.Close()
-		}
-		cancel()
-	}()
-
 	// 初始化readline实例
 	var err error
 	rl, err = readline.New("")
@@ -48,22 +70,137 @@ func main() {
 	}
 	defer rl.Close()
 
+	// 从环境变量获取模型提供方，默认使用智谱AI
+	provider := os.Getenv("LLM_PROVIDER")
+	if provider == "" {
+		provider = llm.PROVIDER_ZHIPU
+	}
+
 	// 从环境变量获取API密钥
-	apiKey := os.Getenv("ZHIPU_API_KEY")
+	apiKeyEnv := "ZHIPU_API_KEY"
+	if provider == llm.PROVIDER_OPENAI {
+		apiKeyEnv = "OPENAI_API_KEY"
+	}
+	apiKey := os.Getenv(apiKeyEnv)
 	if apiKey == "" {
-		logger.Error("未设置 ZHIPU_API_KEY 环境变量, 请设置环境变量: export ZHIPU_API_KEY=your_api_key")
+		logger.Error(fmt.Sprintf("未设置 %s 环境变量, 请设置环境变量: export %s=your_api_key", apiKeyEnv, apiKeyEnv))
 		os.Exit(1)
 	}
 
 	// 创建代理配置
 	config := AgentConfig{
-		APIKey:       apiKey,
-		SystemPrompt: DEFAULT_SYSTEM_PROMPT,
-		Tools:        []string{tools.TOOL_FILE_OPERATION, tools.TOOL_SHELL_COMMAND},
+		Provider:        provider,
+		APIKey:          apiKey,
+		BaseURL:         os.Getenv("LLM_BASE_URL"),
+		Model:           os.Getenv("LLM_MODEL"),
+		Stream:          os.Getenv("LLM_STREAM") != "false",
+		NativeTools:     os.Getenv("LLM_NATIVE_TOOLS") == "true",
+		Retry:           llm.DefaultRetryPolicy(),
+		DisableThinking: os.Getenv("LLM_THINKING") == "false",
+		ShowThinking:    os.Getenv("LLM_SHOW_THINKING") != "false",
+		SystemPrompt:    DEFAULT_SYSTEM_PROMPT,
+		Tools:           []string{tools.TOOL_FILE_OPERATION, tools.TOOL_SHELL_COMMAND},
+	}
+
+	// 允许通过环境变量调整重试次数，0 表示不重试
+	if os.Getenv("LLM_MAX_RETRIES") != "" {
+		config.Retry.MaxAttempts = envInt("LLM_MAX_RETRIES") + 1
+	}
+
+	// 允许通过环境变量指定启用的工具，逗号分隔
+	if value := os.Getenv("AGENT_TOOLS"); value != "" {
+		config.Tools = nil
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				config.Tools = append(config.Tools, name)
+			}
+		}
+	}
+
+	// token预算，0表示不限制
+	config.MaxTurnTokens = envInt("AGENT_MAX_TURN_TOKENS")
+	config.MaxSessionTokens = envInt("AGENT_MAX_SESSION_TOKENS")
+
+	// 上下文窗口大小，未设置时使用默认的128K
+	config.ContextWindow = envInt("AGENT_CONTEXT_WINDOW")
+
+	// 工具调用并发数，未设置时使用默认值
+	config.ToolWorkers = envInt("AGENT_TOOL_WORKERS")
+
+	// 单轮对话的工具调用轮数上限，未设置时使用默认值
+	config.MaxToolRounds = envInt("AGENT_MAX_TOOL_ROUNDS")
+
+	// 写文件、执行命令等操作默认需要确认，设为 true 时自动执行
+	config.AutoApprove = os.Getenv("AGENT_AUTO_APPROVE") == "true"
+
+	// 工具结果的显示方式：quiet, normal, full
+	config.ToolOutput = os.Getenv("AGENT_TOOL_OUTPUT")
+
+	// 项目权限策略文件，默认为 .simple-agent/policy.yaml
+	config.PolicyFile = os.Getenv("AGENT_POLICY_FILE")
+	if config.PolicyFile == "" {
+		config.PolicyFile = tools.DEFAULT_POLICY_FILE
+	}
+
+	// 搜索、列出文件时额外忽略的路径模式，逗号分隔
+	if value := os.Getenv("AGENT_IGNORE"); value != "" {
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				config.IgnorePatterns = append(config.IgnorePatterns, pattern)
+			}
+		}
+	}
+
+	// 会话保存目录
+	sessionDir, err := session.DefaultDir()
+	if err != nil {
+		logger.Error("无法确定会话保存目录，本次会话不会被保存", zap.Error(err))
+	}
+	config.SessionDir = sessionDir
+
+	// 原生函数调用模式下工具定义通过请求下发，使用不含JSON格式说明的提示词
+	if config.NativeTools {
+		config.SystemPrompt = NATIVE_TOOLS_SYSTEM_PROMPT
 	}
 
 	// 创建代理实例
-	agent := NewAdvancedAgent(config, getUserInput)
+	agent, err := NewAdvancedAgent(config, getUserInput, askUser)
+	if err != nil {
+		logger.Error("创建代理失败", zap.Error(err))
+		os.Exit(1)
+	}
+
+	// 按启动参数恢复之前的会话
+	if *resumeID != "" {
+		err = agent.ResumeSession(*resumeID)
+	} else if *continueLatest {
+		err = agent.ContinueLatestSession()
+	}
+	if err != nil {
+		logger.Error("恢复会话失败", zap.Error(err))
+		os.Exit(1)
+	}
+
+	// 设置信号处理：Ctrl+C 中断当前回合，短时间内连按两次或收到 SIGTERM 时退出
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range sigCh {
+			if sig == os.Interrupt && !interrupts.press() {
+				if agent.Interrupt() {
+					fmt.Printf("\n正在中断当前回合，%v 内再次按 Ctrl+C 退出程序\n", doubleInterruptWindow)
+				} else {
+					fmt.Printf("\n%v 内再次按 Ctrl+C 退出程序\n", doubleInterruptWindow)
+				}
+				continue
+			}
+
+			logger.Info("接收到退出信号，正在退出...")
+			rl.Close()
+			cancel()
+			return
+		}
+	}()
 
 	// 显示欢迎信息
 	fmt.Println("\033[32m \n欢迎使用 VCode 智能助手， 输入您的问题或指令，输入'exit'或'quit'退出。 \033[0m")
@@ -76,6 +213,20 @@ func main() {
 	}
 }
 
+// envInt 读取非负整数环境变量，未设置时返回0，格式错误时退出程序
+func envInt(name string) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		logger.Error(name+" 必须是非负整数", zap.String("值", value))
+		os.Exit(1)
+	}
+	return number
+}
+
 // getUserInput 从标准输入获取用户输入
 func getUserInput() (string, bool) {
 	if rl == nil {
@@ -90,8 +241,12 @@ func getUserInput() (string, bool) {
 	input, err := rl.Readline()
 	if err != nil {
 		if err == readline.ErrInterrupt {
-			// Ctrl+C 退出
-			return "", false
+			// 短时间内连按两次 Ctrl+C 退出，否则只提示并继续等待输入
+			if interrupts.press() {
+				return "", false
+			}
+			fmt.Printf("%v 内再次按 Ctrl+C 退出程序\n", doubleInterruptWindow)
+			return "", true
 		}
 		if err == io.EOF {
 			// EOF (Ctrl+D) 退出
@@ -117,3 +272,21 @@ func getUserInput() (string, bool) {
 
 	return input, true
 }
+
+// askUser 显示提示并读取一行回答，defaultValue 会预先填入输入行供用户修改；按 Ctrl+C 或 Ctrl+D 时返回 false
+func askUser(prompt, defaultValue string) (string, bool) {
+	if rl == nil {
+		logger.Debug("readline实例未初始化")
+		return "", false
+	}
+
+	rl.SetPrompt(prompt)
+	input, err := rl.ReadlineWithDefault(defaultValue)
+	if err != nil {
+		if err != readline.ErrInterrupt && err != io.EOF {
+			logger.Error("读取输入失败", zap.Error(err))
+		}
+		return "", false
+	}
+	return input, true
+}
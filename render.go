@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"simple-agent/llm"
+	"simple-agent/tools"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 终端颜色控制码
+const (
+	colorReset  = "\u001b[0m"
+	colorDim    = "\u001b[2m"
+	colorYellow = "\u001b[93m"
+	colorRed    = "\u001b[91m"
+	colorGreen  = "\u001b[92m"
+	colorCyan   = "\u001b[96m"
+)
+
+// 工具结果的显示方式
+const (
+	TOOL_OUTPUT_QUIET  = "quiet"  // 只显示调用和结果状态
+	TOOL_OUTPUT_NORMAL = "normal" // 显示截断后的结果
+	TOOL_OUTPUT_FULL   = "full"   // 显示完整结果
+)
+
+// 工具调用和结果在终端中的显示限制
+const (
+	toolArgsPreviewChars  = 200 // 调用行中参数的最大字符数
+	toolResultPreviewLine = 10  // normal 模式下结果显示的最大行数
+	toolResultLineChars   = 200 // 结果每行显示的最大字符数
+)
+
+// toolResult 一次工具调用及其结果，用于 /expand 展开
+type toolResult struct {
+	call     tools.Tool
+	response tools.ToolCallResponse
+}
+
+// printReply 打印一条完整的模型回复
+func printReply(content string) {
+	fmt.Printf("%s Vcode %s: %s\n", colorYellow, colorReset, content)
+}
+
+// printError 打印一条面向用户的错误提示
+func printError(message string) {
+	fmt.Printf("%s错误%s: %s\n", colorRed, colorReset, message)
+}
+
+// printUsage 以暗色打印一行token用量
+func printUsage(summary string) {
+	fmt.Printf("%s%s%s\n", colorDim, summary, colorReset)
+}
+
+// printReasoning 打印模型的思考过程，折叠时只显示一行摘要
+func printReasoning(reasoning string, expanded bool) {
+	if reasoning == "" {
+		return
+	}
+	if !expanded {
+		printReasoningCollapsed(utf8.RuneCountInString(reasoning))
+		return
+	}
+	fmt.Printf("%s▼ 思考过程\n%s%s\n", colorDim, reasoning, colorReset)
+}
+
+// printReasoningCollapsed 打印折叠状态下的思考过程摘要
+func printReasoningCollapsed(length int) {
+	fmt.Printf("%s▶ 思考过程（已折叠，共 %d 字，输入 /thinking 展开）%s\n", colorDim, length, colorReset)
+}
+
+// approvalPreviewChars 确认工具调用时每个参数最多显示的字符数
+const approvalPreviewChars = 500
+
+// printToolCall 显示一次工具调用：工具、参数和模型给出的理由
+func printToolCall(call tools.Tool) {
+	args, _ := json.Marshal(call.Args)
+	fmt.Printf("%s⚙ %s.%s%s %s%s%s\n", colorCyan, call.Type, call.Name, colorReset, colorDim, truncateRunes(string(args), toolArgsPreviewChars), colorReset)
+	if call.Thought != "" {
+		fmt.Printf("%s  理由: %s%s\n", colorDim, call.Thought, colorReset)
+	}
+}
+
+// printToolApproval 显示等待确认的工具调用的完整参数
+func printToolApproval(call tools.Tool) {
+	fmt.Printf("%s需要确认%s: %s.%s\n", colorYellow, colorReset, call.Type, call.Name)
+	names := make([]string, 0, len(call.Args))
+	for name := range call.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		text, ok := call.Args[name].(string)
+		if !ok {
+			encoded, _ := json.Marshal(call.Args[name])
+			text = string(encoded)
+		}
+		if runes := []rune(text); len(runes) > approvalPreviewChars {
+			text = fmt.Sprintf("%s\n  ...[共 %d 字，仅显示开头]", string(runes[:approvalPreviewChars]), len(runes))
+		}
+		fmt.Printf("  %s: %s\n", name, text)
+	}
+}
+
+// printToolResult 显示一次工具调用的结果，index 大于0时标出序号便于 /expand 展开
+func printToolResult(index int, result toolResult, output string) {
+	label := result.call.Type + "." + result.call.Name
+	if index > 0 {
+		label = fmt.Sprintf("[%d] %s", index, label)
+	}
+
+	body := strings.TrimRight(result.response.Result, "\n")
+	lines := strings.Split(body, "\n")
+	if body == "" {
+		lines = nil
+	}
+
+	if result.response.Error != "" {
+		fmt.Printf("%s✗ %s: %s%s\n", colorRed, label, result.response.Error, colorReset)
+	} else {
+		fmt.Printf("%s✓ %s%s %s(%d 行)%s\n", colorGreen, label, colorReset, colorDim, len(lines), colorReset)
+	}
+	if output == TOOL_OUTPUT_QUIET || len(lines) == 0 {
+		return
+	}
+
+	shown := lines
+	if output != TOOL_OUTPUT_FULL && len(lines) > toolResultPreviewLine {
+		shown = lines[:toolResultPreviewLine]
+	}
+	for _, line := range shown {
+		if output != TOOL_OUTPUT_FULL {
+			line = truncateRunes(line, toolResultLineChars)
+		}
+		fmt.Printf("%s  │ %s%s\n", colorDim, line, colorReset)
+	}
+	if hidden := len(lines) - len(shown); hidden > 0 {
+		hint := "/expand"
+		if index > 0 {
+			hint = fmt.Sprintf("/expand %d", index)
+		}
+		fmt.Printf("%s  … 还有 %d 行，输入 %s 查看完整结果%s\n", colorDim, hidden, hint, colorReset)
+	}
+}
+
+// printToolResults 按调用顺序显示一批工具调用的结果
+func printToolResults(results []toolResult, output string) {
+	for i, result := range results {
+		index := 0
+		if len(results) > 1 {
+			index = i + 1
+		}
+		printToolResult(index, result, output)
+	}
+}
+
+// truncateRunes 截断过长的文本，末尾加省略号
+func truncateRunes(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars]) + "..."
+}
+
+// streamPrinter 将流式增量实时输出到终端
+type streamPrinter struct {
+	showThinking bool // 是否实时展开思考过程
+	started      bool // 是否已输出回复前缀
+	thinking     bool // 是否正在输出展开的思考过程
+	reasoningLen int  // 已收到的思考过程字数
+	summarized   bool // 折叠状态下是否已输出思考过程摘要
+}
+
+// onDelta 输出一段增量内容，首段内容前先输出回复前缀
+func (p *streamPrinter) onDelta(delta llm.Delta) {
+	if delta.ReasoningContent != "" {
+		p.reasoningLen += utf8.RuneCountInString(delta.ReasoningContent)
+		if p.showThinking {
+			if !p.thinking {
+				fmt.Printf("%s▼ 思考过程\n", colorDim)
+				p.thinking = true
+			}
+			fmt.Print(delta.ReasoningContent)
+		}
+	}
+
+	if delta.Content == "" {
+		return
+	}
+	p.endThinking()
+	if !p.started {
+		fmt.Printf("%s Vcode %s: ", colorYellow, colorReset)
+		p.started = true
+	}
+	fmt.Print(delta.Content)
+}
+
+// endThinking 结束思考过程的输出，折叠时补一行摘要
+func (p *streamPrinter) endThinking() {
+	if p.thinking {
+		fmt.Printf("%s\n", colorReset)
+		p.thinking = false
+		return
+	}
+	if !p.showThinking && p.reasoningLen > 0 && !p.summarized {
+		printReasoningCollapsed(p.reasoningLen)
+		p.summarized = true
+	}
+}
+
+// finish 结束本次流式输出，补齐换行
+func (p *streamPrinter) finish() {
+	p.endThinking()
+	if p.started {
+		fmt.Println()
+	}
+}
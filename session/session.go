@@ -0,0 +1,309 @@
+package session
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"simple-agent/llm"
+	"simple-agent/logger"
+
+	"go.uber.org/zap"
+)
+
+// 会话目录中的文件名
+const (
+	metaFileName     = "meta.json"      // 会话元信息
+	messagesFileName = "messages.jsonl" // 对话消息，每行一条
+)
+
+// Meta 会话元信息
+type Meta struct {
+	ID           string    `json:"id"`            // 会话ID
+	Title        string    `json:"title"`         // 会话标题
+	CreatedAt    time.Time `json:"created_at"`    // 创建时间
+	UpdatedAt    time.Time `json:"updated_at"`    // 最后更新时间
+	Provider     string    `json:"provider"`      // 模型提供方
+	Model        string    `json:"model"`         // 模型名称
+	Workspace    string    `json:"workspace"`     // 工作目录
+	MessageCount int       `json:"message_count"` // 消息数量
+}
+
+// Session 一个持久化的会话，消息逐条追加写入磁盘
+type Session struct {
+	Meta
+	Messages []llm.Message // 对话消息
+
+	dir string // 会话目录
+}
+
+// Store 会话存储，每个会话保存在 <dir>/<id>/ 下
+type Store struct {
+	dir string
+}
+
+// DefaultDir 返回默认的会话目录：$SIMPLE_AGENT_HOME/sessions，未设置时为 ~/.simple-agent/sessions
+func DefaultDir() (string, error) {
+	home := os.Getenv("SIMPLE_AGENT_HOME")
+	if home == "" {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("无法获取用户目录: %v", err)
+		}
+		home = filepath.Join(userHome, ".simple-agent")
+	}
+	return filepath.Join(home, "sessions"), nil
+}
+
+// NewStore 创建会话存储，目录不存在时自动创建
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("无法创建会话目录 %s: %v", dir, err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Create 创建一个新会话并写入初始消息
+func (s *Store) Create(meta Meta, messages []llm.Message) (*Session, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	meta.ID = id
+	meta.CreatedAt = now
+	meta.UpdatedAt = now
+
+	session := &Session{Meta: meta, dir: filepath.Join(s.dir, id)}
+	if err := os.MkdirAll(session.dir, 0755); err != nil {
+		return nil, fmt.Errorf("无法创建会话目录 %s: %v", session.dir, err)
+	}
+	if err := session.Rewrite(messages); err != nil {
+		return nil, err
+	}
+
+	logger.Info("已创建会话", zap.String("id", id), zap.String("目录", session.dir))
+	return session, nil
+}
+
+// Open 加载指定ID的会话，末尾因崩溃写了一半的消息会被忽略
+func (s *Store) Open(id string) (*Session, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, fmt.Errorf("无效的会话ID: %s", id)
+	}
+
+	dir := filepath.Join(s.dir, id)
+	meta, err := readMeta(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, skipped, err := readMessages(filepath.Join(dir, messagesFileName))
+	if err != nil {
+		return nil, err
+	}
+	meta.MessageCount = len(messages)
+
+	session := &Session{Meta: meta, Messages: messages, dir: dir}
+
+	// 存在损坏的行时重写文件，避免之后追加的消息接在半行内容后面
+	if skipped > 0 {
+		if err := session.Rewrite(messages); err != nil {
+			return nil, err
+		}
+	}
+	return session, nil
+}
+
+// List 列出所有会话，按最后更新时间倒序
+func (s *Store) List() ([]Meta, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取会话目录 %s: %v", s.dir, err)
+	}
+
+	var metas []Meta
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		meta, err := readMeta(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			logger.Warn("跳过无法读取的会话", zap.String("id", entry.Name()), zap.Error(err))
+			continue
+		}
+		metas = append(metas, meta)
+	}
+
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].UpdatedAt.After(metas[j].UpdatedAt)
+	})
+	return metas, nil
+}
+
+// Latest 返回最近更新的会话，没有会话时返回错误
+func (s *Store) Latest() (*Session, error) {
+	metas, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(metas) == 0 {
+		return nil, fmt.Errorf("没有可以继续的会话")
+	}
+	return s.Open(metas[0].ID)
+}
+
+// Append 追加一条消息，消息行写入后立即同步到磁盘
+func (s *Session) Append(message llm.Message) error {
+	line, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("无法序列化消息: %v", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(s.dir, messagesFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("无法打开会话文件: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("无法写入会话文件: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("无法同步会话文件: %v", err)
+	}
+
+	s.Messages = append(s.Messages, message)
+	s.MessageCount = len(s.Messages)
+	s.UpdatedAt = time.Now()
+	return s.writeMeta()
+}
+
+// Rewrite 用新的消息列表整体替换会话内容（如压缩历史后），通过临时文件原子替换
+func (s *Session) Rewrite(messages []llm.Message) error {
+	var content strings.Builder
+	for _, message := range messages {
+		line, err := json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("无法序列化消息: %v", err)
+		}
+		content.Write(line)
+		content.WriteByte('\n')
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.dir, messagesFileName), []byte(content.String())); err != nil {
+		return err
+	}
+
+	s.Messages = append([]llm.Message(nil), messages...)
+	s.MessageCount = len(s.Messages)
+	s.UpdatedAt = time.Now()
+	return s.writeMeta()
+}
+
+// SetTitle 修改会话标题
+func (s *Session) SetTitle(title string) error {
+	s.Title = title
+	s.UpdatedAt = time.Now()
+	return s.writeMeta()
+}
+
+// writeMeta 写入会话元信息
+func (s *Session) writeMeta() error {
+	data, err := json.MarshalIndent(s.Meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("无法序列化会话信息: %v", err)
+	}
+	return writeFileAtomic(filepath.Join(s.dir, metaFileName), data)
+}
+
+// readMeta 读取会话目录中的元信息
+func readMeta(dir string) (Meta, error) {
+	var meta Meta
+	data, err := ioutil.ReadFile(filepath.Join(dir, metaFileName))
+	if err != nil {
+		return meta, fmt.Errorf("无法读取会话信息: %v", err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("会话信息格式错误: %v", err)
+	}
+	return meta, nil
+}
+
+// readMessages 逐行读取消息文件，返回消息和无法解析而跳过的行数
+func readMessages(path string) ([]llm.Message, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("无法读取会话消息: %v", err)
+	}
+	defer file.Close()
+
+	var messages []llm.Message
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var message llm.Message
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			// 崩溃时最后一行可能只写了一半，跳过即可
+			logger.Warn("跳过无法解析的会话消息", zap.String("文件", path), zap.Error(err))
+			skipped++
+			continue
+		}
+		messages = append(messages, message)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("无法读取会话消息: %v", err)
+	}
+	return messages, skipped, nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免写到一半时崩溃留下损坏的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return fmt.Errorf("无法创建临时文件: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("无法写入临时文件: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("无法同步临时文件: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("无法关闭临时文件: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("无法替换文件 %s: %v", path, err)
+	}
+	return nil
+}
+
+// newID 生成形如 20060102-150405-a1b2c3 的会话ID，按字典序即按时间排序
+func newID() (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("无法生成会话ID: %v", err)
+	}
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix), nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"simple-agent/llm"
+	"simple-agent/logger"
+	"simple-agent/session"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// sessionTitleLength 自动生成的会话标题最大字数
+const sessionTitleLength = 30
+
+// appendMessage 追加一条消息到对话历史，并增量写入会话文件
+func (a *AdvancedAgent) appendMessage(message llm.Message) {
+	a.conversation = append(a.conversation, message)
+	if a.sessions == nil {
+		return
+	}
+
+	// 第一条消息写入时才创建会话，避免产生空会话
+	if a.session == nil {
+		a.createSession("")
+		return
+	}
+
+	if err := a.session.Append(message); err != nil {
+		logger.Error("写入会话失败", zap.String("会话", a.session.ID), zap.Error(err))
+	}
+}
+
+// replaceConversation 整体替换对话历史（如压缩后），并同步重写会话文件
+func (a *AdvancedAgent) replaceConversation(messages []llm.Message) {
+	a.conversation = messages
+	if a.session == nil {
+		return
+	}
+	if err := a.session.Rewrite(messages); err != nil {
+		logger.Error("重写会话失败", zap.String("会话", a.session.ID), zap.Error(err))
+	}
+}
+
+// createSession 用当前对话历史创建会话，title 为空时取第一条用户消息作为标题
+func (a *AdvancedAgent) createSession(title string) {
+	if title == "" {
+		title = defaultSessionTitle(a.conversation)
+	}
+	workspace, _ := os.Getwd()
+
+	created, err := a.sessions.Create(session.Meta{
+		Title:     title,
+		Provider:  a.config.Provider,
+		Model:     a.config.Model,
+		Workspace: workspace,
+	}, a.conversation)
+	if err != nil {
+		logger.Error("创建会话失败", zap.Error(err))
+		printError(fmt.Sprintf("创建会话失败，本次对话不会被保存: %v", err))
+		a.sessions = nil
+		return
+	}
+	a.session = created
+}
+
+// defaultSessionTitle 取第一条用户消息的开头作为会话标题
+func defaultSessionTitle(messages []llm.Message) string {
+	for _, message := range messages {
+		if message.Role != "user" {
+			continue
+		}
+		title := []rune(strings.Join(strings.Fields(message.Content), " "))
+		if len(title) > sessionTitleLength {
+			return string(title[:sessionTitleLength]) + "..."
+		}
+		return string(title)
+	}
+	return "新会话"
+}
+
+// ResumeSession 恢复指定ID的会话
+func (a *AdvancedAgent) ResumeSession(id string) error {
+	if a.sessions == nil {
+		return fmt.Errorf("未启用会话保存")
+	}
+	loaded, err := a.sessions.Open(id)
+	if err != nil {
+		return err
+	}
+	a.loadSession(loaded)
+	return nil
+}
+
+// ContinueLatestSession 继续最近一次更新的会话
+func (a *AdvancedAgent) ContinueLatestSession() error {
+	if a.sessions == nil {
+		return fmt.Errorf("未启用会话保存")
+	}
+	latest, err := a.sessions.Latest()
+	if err != nil {
+		return err
+	}
+	a.loadSession(latest)
+	return nil
+}
+
+// loadSession 切换到已加载的会话，系统提示词使用当前配置
+func (a *AdvancedAgent) loadSession(loaded *session.Session) {
+	conversation := append([]llm.Message(nil), loaded.Messages...)
+	if len(conversation) > 0 && conversation[0].Role == "system" {
+		conversation[0].Content = a.config.SystemPrompt
+	} else {
+		conversation = append([]llm.Message{{Role: "system", Content: a.config.SystemPrompt}}, conversation...)
+	}
+
+	a.conversation = conversation
+	a.session = loaded
+	a.lastReasoning = ""
+
+	fmt.Printf("已恢复会话 %s「%s」，共 %d 条消息\n", loaded.ID, loaded.Title, len(loaded.Messages))
+	if workspace, _ := os.Getwd(); loaded.Workspace != "" && loaded.Workspace != workspace {
+		fmt.Printf("%s注意: 该会话创建于 %s，与当前工作目录不同%s\n", colorDim, loaded.Workspace, colorReset)
+	}
+}
+
+// handleSessionsCommand 处理 /sessions 命令，列出已保存的会话
+func (a *AdvancedAgent) handleSessionsCommand() {
+	if a.sessions == nil {
+		fmt.Println("未启用会话保存")
+		return
+	}
+	metas, err := a.sessions.List()
+	if err != nil {
+		printError(fmt.Sprintf("读取会话列表失败: %v", err))
+		return
+	}
+	if len(metas) == 0 {
+		fmt.Println("暂无保存的会话")
+		return
+	}
+
+	for _, meta := range metas {
+		marker := " "
+		if a.session != nil && a.session.ID == meta.ID {
+			marker = "*"
+		}
+		fmt.Printf("%s %s  %s  %3d 条消息  %s\n", marker, meta.ID, meta.UpdatedAt.Format("2006-01-02 15:04"), meta.MessageCount, meta.Title)
+	}
+	fmt.Println("使用 /load <会话ID> 切换会话，或启动时使用 --resume <会话ID>")
+}
+
+// handleSaveCommand 处理 /save 命令，立即保存当前会话并可设置标题
+func (a *AdvancedAgent) handleSaveCommand(args []string) {
+	if a.sessions == nil {
+		fmt.Println("未启用会话保存")
+		return
+	}
+
+	title := strings.Join(args, " ")
+	if a.session == nil {
+		a.createSession(title)
+		if a.session == nil {
+			return
+		}
+	} else if title != "" {
+		if err := a.session.SetTitle(title); err != nil {
+			printError(fmt.Sprintf("保存会话标题失败: %v", err))
+			return
+		}
+	}
+
+	fmt.Printf("会话已保存: %s「%s」\n", a.session.ID, a.session.Title)
+}
+
+// handleLoadCommand 处理 /load 命令，切换到指定会话
+func (a *AdvancedAgent) handleLoadCommand(args []string) {
+	if len(args) != 1 {
+		fmt.Println("用法: /load <会话ID>")
+		return
+	}
+	if err := a.ResumeSession(args[0]); err != nil {
+		printError(fmt.Sprintf("加载会话失败: %v", err))
+	}
+}
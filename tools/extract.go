@@ -0,0 +1,389 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"simple-agent/logger"
+
+	"go.uber.org/zap"
+)
+
+// toolBlockLanguages 可能包含工具调用的代码块语言标记，其他语言（如 go、python）的代码块整体跳过
+var toolBlockLanguages = map[string]bool{
+	"":           true,
+	"json":       true,
+	"jsonc":      true,
+	"tool":       true,
+	"tool_call":  true,
+	"tool_calls": true,
+}
+
+// ToolCallFormatError 回复中有看起来是工具调用、但无法解析或格式不对的内容
+type ToolCallFormatError struct {
+	Problems []string // 每处问题一行
+}
+
+// Error 实现 error 接口
+func (e *ToolCallFormatError) Error() string {
+	return "工具调用格式错误:\n- " + strings.Join(e.Problems, "\n- ")
+}
+
+// ExtractTools 从模型回复中提取工具调用，没有工具调用时返回空列表。
+// 依次扫描 JSON 代码块和代码块之外的正文，只接受形如 {"type", "name", "args"} 的对象或这类对象组成的数组，
+// 普通回答中的代码和 JSON 不会被误认为工具调用；看起来是工具调用但格式不对时返回 *ToolCallFormatError，
+// 此时不返回任何调用，避免只执行其中一部分
+func ExtractTools(content string) ([]Tool, error) {
+	logger.Debug("开始提取工具调用", zap.Int("content_length", len(content)))
+
+	var tools []Tool
+	var problems []string
+	for _, segment := range splitFencedBlocks(content) {
+		if segment.fenced && !toolBlockLanguages[segment.language] {
+			continue
+		}
+		found, invalid := scanToolCalls(segment.text)
+		tools = append(tools, found...)
+		problems = append(problems, invalid...)
+	}
+
+	if len(problems) > 0 {
+		logger.Warn("工具调用格式错误", zap.Strings("问题", problems))
+		return nil, &ToolCallFormatError{Problems: problems}
+	}
+	if len(tools) > 0 {
+		logger.Info("成功解析工具调用", zap.Int("tool_count", len(tools)))
+	}
+	return tools, nil
+}
+
+// textSegment 回复中的一段文本：代码块或代码块之外的正文
+type textSegment struct {
+	text     string // 文本内容，代码块不含围栏行
+	fenced   bool   // 是否为代码块
+	language string // 代码块的语言标记，小写
+}
+
+// splitFencedBlocks 按 ``` 或 ~~~ 围栏将回复拆分为正文和代码块，未闭合的代码块延续到末尾
+func splitFencedBlocks(content string) []textSegment {
+	var segments []textSegment
+	var current strings.Builder
+	var fence string
+	language := ""
+
+	flush := func(fenced bool) {
+		if current.Len() > 0 || fenced {
+			segments = append(segments, textSegment{text: current.String(), fenced: fenced, language: language})
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			if marker := fenceMarker(trimmed); marker != "" {
+				flush(false)
+				fence = marker
+				language = blockLanguage(trimmed[len(marker):])
+				continue
+			}
+		} else if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			flush(true)
+			fence = ""
+			language = ""
+			continue
+		}
+		current.WriteString(line)
+	}
+	flush(fence != "")
+	return segments
+}
+
+// fenceMarker 返回行首的代码块围栏（至少三个 ` 或 ~），不是围栏时返回空
+func fenceMarker(line string) string {
+	if len(line) < 3 || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	count := 0
+	for count < len(line) && line[count] == line[0] {
+		count++
+	}
+	if count < 3 {
+		return ""
+	}
+	return line[:count]
+}
+
+// blockLanguage 取代码块信息串中的语言标记
+func blockLanguage(info string) string {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// scanToolCalls 在一段文本中依次查找完整的 JSON 值，收集其中符合工具调用格式的部分，以及看起来是工具调用但有问题的部分。
+// 找到的 JSON 值整体跳过，嵌套在其他 JSON 中的对象不会被单独识别为工具调用。正文中的括号可能不配对或者括起的不是 JSON，
+// 这时只跳过开头的括号继续查找：括号内找到工具调用时不报告格式错误，其后再没有能解析的工具调用时才报告括号不完整
+func scanToolCalls(text string) ([]Tool, []string) {
+	var tools []Tool
+	var problems []string
+	truncated := ""                  // 最近一个括号不完整、看起来是工具调用的片段
+	malformed, malformedEnd := "", 0 // 尚未确认的 JSON 格式错误及其结束位置
+	for i := 0; i < len(text); i++ {
+		if malformed != "" && i >= malformedEnd {
+			problems = append(problems, malformed)
+			malformed = ""
+		}
+		if text[i] != '{' && text[i] != '[' {
+			continue
+		}
+		end, ok := scanJSONValue(text, i)
+		if !ok {
+			// 可能是正文中单独的括号，也可能是被截断的回复，继续向后查找
+			if truncated == "" && looksLikeToolCallText(text[i:]) {
+				truncated = text[i:]
+			}
+			continue
+		}
+
+		raw := text[i:end]
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			if malformed == "" && looksLikeToolCallText(raw) {
+				malformed = fmt.Sprintf("JSON 格式错误（%v）: %s", err, snippet(raw))
+				malformedEnd = end
+			}
+			continue
+		}
+
+		calls, invalid := decodeToolCalls(value)
+		if len(calls) > 0 || len(invalid) > 0 {
+			truncated = ""
+			if malformed != "" && i < malformedEnd {
+				malformed = ""
+			}
+		}
+		tools = append(tools, calls...)
+		problems = append(problems, invalid...)
+		i = end - 1
+	}
+	if malformed != "" {
+		problems = append(problems, malformed)
+	}
+	if truncated != "" {
+		problems = append(problems, fmt.Sprintf("JSON 不完整，括号没有闭合: %s", snippet(truncated)))
+	}
+	return tools, problems
+}
+
+// scanJSONValue 从 start 处的 { 或 [ 开始找到配对的结束括号，跳过字符串中的括号和转义的引号，
+// 返回结束位置（不含），括号不配对或文本提前结束时返回 false
+func scanJSONValue(text string, start int) (int, bool) {
+	var stack []byte
+	inString := false
+	escaped := false
+
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != c {
+				return 0, false
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// decodeToolCalls 将 JSON 值解析为工具调用，接受工具调用对象或由工具调用对象组成的数组；
+// 不像工具调用的值直接忽略，像工具调用但格式不对时返回问题说明
+func decodeToolCalls(value interface{}) ([]Tool, []string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if !looksLikeToolCall(v) {
+			return nil, nil
+		}
+		tool, problem := toolFromObject(v)
+		if problem != "" {
+			return nil, []string{problem}
+		}
+		return []Tool{tool}, nil
+
+	case []interface{}:
+		isToolArray := false
+		for _, item := range v {
+			if object, ok := item.(map[string]interface{}); ok && looksLikeToolCall(object) {
+				isToolArray = true
+				break
+			}
+		}
+		if !isToolArray {
+			return nil, nil
+		}
+
+		var tools []Tool
+		var problems []string
+		for i, item := range v {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				problems = append(problems, fmt.Sprintf("第 %d 个工具调用应为对象，实际为 %s", i+1, jsonTypeName(item)))
+				continue
+			}
+			tool, problem := toolFromObject(object)
+			if problem != "" {
+				problems = append(problems, fmt.Sprintf("第 %d 个%s", i+1, problem))
+				continue
+			}
+			tools = append(tools, tool)
+		}
+		if len(problems) > 0 {
+			return nil, problems
+		}
+		return tools, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// toolCallTypes 内置工具的类型，type 为这些值的对象即使缺少 args 也认为是在尝试调用工具
+var toolCallTypes = []string{TOOL_FILE_OPERATION, TOOL_SHELL_COMMAND}
+
+// toolCallTypePattern 在无法解析的 JSON 文本中查找内置工具类型
+var toolCallTypePattern = regexp.MustCompile(`"type"\s*:\s*"(` + strings.Join(toolCallTypes, "|") + `)"`)
+
+// looksLikeToolCall 判断对象是否是在尝试调用工具：有 type 和 name，并且带有 args 字段或 type 是内置工具类型；
+// 函数定义等带 parameters 的普通 JSON 不算
+func looksLikeToolCall(object map[string]interface{}) bool {
+	toolType, hasType := object["type"]
+	_, hasName := object["name"]
+	if !hasType || !hasName {
+		return false
+	}
+	if _, ok := object["args"]; ok {
+		return true
+	}
+	for _, known := range toolCallTypes {
+		if toolType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeToolCallText 判断无法解析的 JSON 文本是否是在尝试调用工具
+func looksLikeToolCallText(text string) bool {
+	if !strings.Contains(text, `"type"`) || !strings.Contains(text, `"name"`) {
+		return false
+	}
+	return strings.Contains(text, `"args"`) || toolCallTypePattern.MatchString(text)
+}
+
+// toolFromObject 按工具调用格式解析对象：type 和 name 为非空字符串，args 为对象，thought 为字符串，
+// 不允许出现其他字段；格式不对时返回问题说明
+func toolFromObject(object map[string]interface{}) (Tool, string) {
+	var tool Tool
+	var problems []string
+
+	// 按字段名排序，保证问题说明稳定
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := object[key]
+		switch key {
+		case "type", "name", "thought", "id":
+			text, ok := value.(string)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("字段 %s 应为字符串，实际为 %s", key, jsonTypeName(value)))
+				continue
+			}
+			switch key {
+			case "type":
+				tool.Type = text
+			case "name":
+				tool.Name = text
+			case "thought":
+				tool.Thought = text
+			case "id":
+				tool.ID = text
+			}
+		case "args":
+			if value == nil {
+				continue
+			}
+			args, ok := value.(map[string]interface{})
+			if !ok {
+				problems = append(problems, fmt.Sprintf("字段 args 应为对象，实际为 %s", jsonTypeName(value)))
+				continue
+			}
+			tool.Args = args
+		default:
+			problems = append(problems, fmt.Sprintf("不支持的字段 %q，只能包含 type、name、args、thought", key))
+		}
+	}
+
+	for _, key := range []string{"type", "name"} {
+		value, ok := object[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("缺少字段 %s", key))
+		} else if text, ok := value.(string); ok && text == "" {
+			problems = append(problems, fmt.Sprintf("字段 %s 不能为空", key))
+		}
+	}
+	if len(problems) > 0 {
+		return Tool{}, fmt.Sprintf("工具调用 %s.%s: %s", displayValue(object["type"]), displayValue(object["name"]), strings.Join(problems, "；"))
+	}
+	return tool, ""
+}
+
+// displayValue 将字段值转为用于错误信息的文本
+func displayValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// snippetLength 错误信息中引用原文的最大字符数
+const snippetLength = 200
+
+// snippet 截取一段原文用于错误信息
+func snippet(text string) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= snippetLength {
+		return string(runes)
+	}
+	return string(runes[:snippetLength]) + "..."
+}
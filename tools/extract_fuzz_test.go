@@ -0,0 +1,32 @@
+//go:build go1.18
+// +build go1.18
+
+package tools
+
+import "testing"
+
+// FuzzExtractTools 检查任意回复都不会导致提取时 panic，并且结果满足 ExtractTools 的约定：
+// 出错时不返回任何调用，返回的调用都有 type 和 name
+func FuzzExtractTools(f *testing.F) {
+	for _, test := range extractCorpus {
+		f.Add(test.content)
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		tools, err := ExtractTools(content)
+		if err != nil {
+			if _, ok := err.(*ToolCallFormatError); !ok {
+				t.Fatalf("错误类型为 %T，期望 *ToolCallFormatError", err)
+			}
+			if len(tools) > 0 {
+				t.Fatalf("出错时返回了 %d 个工具调用", len(tools))
+			}
+			return
+		}
+		for _, tool := range tools {
+			if tool.Type == "" || tool.Name == "" {
+				t.Fatalf("工具调用缺少 type 或 name: %+v", tool)
+			}
+		}
+	})
+}
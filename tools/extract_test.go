@@ -0,0 +1,163 @@
+package tools
+
+import "testing"
+
+// extractCase 一段模型回复和期望提取的结果
+type extractCase struct {
+	name    string
+	content string
+	tools   []string // 期望提取的工具调用，形如 type.name
+	err     bool     // 是否期望返回格式错误
+}
+
+// extractCorpus 模型回复的样例，同时作为 FuzzExtractTools 的种子
+var extractCorpus = []extractCase{
+	{
+		name:    "纯文本回答",
+		content: "你好！我是一个智能助手，可以帮你读写文件和执行命令。",
+	},
+	{
+		name:    "单个工具调用",
+		content: `{"type": "file_operation", "name": "list", "args": {"path": "."}}`,
+		tools:   []string{"file_operation.list"},
+	},
+	{
+		name:    "正文之后的 JSON 代码块",
+		content: "我将使用文件操作工具来列出当前目录的内容。\n\n```json\n{\n  \"type\": \"file_operation\",\n  \"name\": \"list\",\n  \"args\": {\n    \"path\": \".\"\n  },\n  \"thought\": \"先了解项目结构\"\n}\n```",
+		tools:   []string{"file_operation.list"},
+	},
+	{
+		name:    "正文中间的工具调用数组",
+		content: "先读取两个文件：[{\"type\":\"file_operation\",\"name\":\"read\",\"args\":{\"path\":\"main.go\"}},{\"type\":\"file_operation\",\"name\":\"read\",\"args\":{\"path\":\"go.mod\"}}] 然后再分析。",
+		tools:   []string{"file_operation.read", "file_operation.read"},
+	},
+	{
+		name:    "Go 代码块中的 JSON 不是工具调用",
+		content: "示例代码:\n```go\nvar s = `{\"type\": \"file_operation\", \"name\": \"list\", \"args\": {}}`\nfunc main() { fmt.Println(s) }\n```\n以上就是全部内容。",
+	},
+	{
+		name:    "Go 代码块之后的工具调用",
+		content: "```go\nfunc main() {\n\tif x { return }\n}\n```\n现在写入文件：\n```json\n{\"type\":\"file_operation\",\"name\":\"write\",\"args\":{\"path\":\"main.go\",\"content\":\"package main\\n\\nfunc main() {}\\n\"}}\n```",
+		tools:   []string{"file_operation.write"},
+	},
+	{
+		name:    "字符串中的转义引号和括号",
+		content: `{"type":"shell_command","name":"execute","args":{"command":"echo \"{[}]\" && grep -n \"func (\" *.go"}}`,
+		tools:   []string{"shell_command.execute"},
+	},
+	{
+		name:    "字符串中的反斜杠",
+		content: `{"type":"shell_command","name":"execute","args":{"command":"printf 'a\\n' | sed 's/\\\\/\//g'"}}`,
+		tools:   []string{"shell_command.execute"},
+	},
+	{
+		name:    "多个代码块",
+		content: "第一步:\n```json\n{\"type\":\"file_operation\",\"name\":\"list\",\"args\":{}}\n```\n第二步:\n~~~tool_call\n{\"type\":\"shell_command\",\"name\":\"execute\",\"args\":{\"command\":\"go test ./...\"}}\n~~~\n",
+		tools:   []string{"file_operation.list", "shell_command.execute"},
+	},
+	{
+		name:    "普通 JSON 数据不是工具调用",
+		content: "配置文件内容如下:\n```json\n{\"name\": \"simple-agent\", \"version\": \"1.0.0\", \"scripts\": {\"test\": \"go test\"}}\n```",
+	},
+	{
+		name:    "嵌套在其他 JSON 中的对象不单独识别",
+		content: `{"example": {"type": "file_operation", "name": "list", "args": {}}}`,
+	},
+	{
+		name:    "正文中不配对的左花括号",
+		content: "使用 { 符号开始一个代码块，下面列出目录:\n[{\"type\":\"file_operation\",\"name\":\"list\",\"args\":{}}]",
+		tools:   []string{"file_operation.list"},
+	},
+	{
+		name:    "正文中不配对的左方括号",
+		content: "数组写作 [1, 2 然后\n[{\"type\":\"file_operation\",\"name\":\"list\",\"args\":{}}]",
+		tools:   []string{"file_operation.list"},
+	},
+	{
+		name:    "括号内不是 JSON",
+		content: "结构体 { 见下面 [{\"type\":\"file_operation\",\"name\":\"read\",\"args\":{\"path\":\"a.go\"}}] }",
+		tools:   []string{"file_operation.read"},
+	},
+	{
+		name:    "多余的右括号",
+		content: "} ] 列出目录 {\"type\":\"file_operation\",\"name\":\"list\",\"args\":{}}",
+		tools:   []string{"file_operation.list"},
+	},
+	{
+		name:    "回复被截断",
+		content: "我来读取文件:\n{\"type\":\"file_operation\",\"name\":\"read\",\"args\":{\"path\":",
+		err:     true,
+	},
+	{
+		name:    "完整调用之后被截断",
+		content: "[{\"type\":\"file_operation\",\"name\":\"list\",\"args\":{}}, {\"type\":\"file_operation\",\"name\":\"read\",\"args\":{",
+		err:     true,
+	},
+	{
+		name:    "未闭合的代码块",
+		content: "```json\n{\"type\":\"file_operation\",\"name\":\"list\",\"args\":{}}",
+		tools:   []string{"file_operation.list"},
+	},
+	{
+		name:    "尾随逗号",
+		content: "{\"type\":\"file_operation\",\"name\":\"list\",\"args\":{},}",
+		err:     true,
+	},
+	{
+		name:    "使用 arguments 字段",
+		content: `{"type":"file_operation","name":"read","arguments":{"path":"a.go"}}`,
+		err:     true,
+	},
+	{
+		name:    "函数定义不是工具调用",
+		content: "天气查询函数的定义如下:\n```json\n{\"type\":\"function\",\"name\":\"get_weather\",\"parameters\":{\"type\":\"object\",\"properties\":{\"city\":{\"type\":\"string\"}}}}\n```",
+	},
+	{
+		name:    "未知类型的截断函数定义不报错",
+		content: "{\"type\":\"function\",\"name\":\"get_weather\",\"parameters\":{\"type\":",
+	},
+	{
+		name:    "未知类型但带 args 字段",
+		content: `{"type":"browser","name":"open","args":{"url":"https://example.com"}}`,
+		tools:   []string{"browser.open"},
+	},
+	{
+		name:    "args 不是对象",
+		content: `{"type":"file_operation","name":"read","args":"a.go"}`,
+		err:     true,
+	},
+	{
+		name:    "部分调用格式错误时不返回任何调用",
+		content: `[{"type":"file_operation","name":"list","args":{}},{"type":"file_operation","name":"","args":{}}]`,
+		err:     true,
+	},
+	{
+		name:    "数组中混有不是工具调用的对象",
+		content: `[{},{"type":"file_operation","name":"list","args":{}}]`,
+		err:     true,
+	},
+}
+
+func TestExtractToolsCorpus(t *testing.T) {
+	for _, test := range extractCorpus {
+		t.Run(test.name, func(t *testing.T) {
+			tools, err := ExtractTools(test.content)
+			if (err != nil) != test.err {
+				t.Fatalf("错误为 %v，期望出错: %v", err, test.err)
+			}
+			var got []string
+			for _, tool := range tools {
+				got = append(got, tool.Type+"."+tool.Name)
+			}
+			if len(got) != len(test.tools) {
+				t.Fatalf("提取到 %v，期望 %v", got, test.tools)
+			}
+			for i := range got {
+				if got[i] != test.tools[i] {
+					t.Errorf("提取到 %v，期望 %v", got, test.tools)
+					break
+				}
+			}
+		})
+	}
+}
@@ -1,86 +1,243 @@
 package tools
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// ExecuteFileOperation 执行文件操作
-func ExecuteFileOperation(tool Tool) ToolCallResponse {
-	switch tool.Name {
-	case "list":
-		// 获取目录参数
-		dir, ok := tool.Args["path"].(string)
-		if !ok {
-			dir = "."
-		}
+// maxPathLength 路径参数的最大长度
+const maxPathLength = 1024
 
-		// 安全检查：限制目录访问范围
-		if strings.Contains(dir, "..") || strings.HasPrefix(dir, "/") {
-			return ToolCallResponse{Error: "出于安全考虑，禁止访问上级目录或绝对路径"}
-		}
+// 目录列表的限制
+const (
+	defaultListDepth   = 2         // 默认展开的目录层数
+	maxListDepth       = 10        // 最多展开的目录层数
+	defaultListEntries = 200       // 默认最多列出的条目数
+	maxListEntries     = 1000      // 最多列出的条目数上限
+	maxListOutputBytes = 64 * 1024 // 列表输出的最大字节数
+)
 
-		// 列出目录内容
-		result, err := listDirectory(dir)
-		if err != nil {
-			return ToolCallResponse{Error: err.Error()}
-		}
-		return ToolCallResponse{Result: result}
+// 文件读取的限制
+const (
+	defaultReadLines  = 2000      // 默认读取的行数
+	maxReadLines      = 10000     // 单次最多读取的行数
+	maxReadBytes      = 64 * 1024 // 单次读取输出的最大字节数
+	maxReadLineLength = 2000      // 每行最多显示的字符数
+)
 
-	case "read":
-		// 获取文件路径参数
-		path, ok := tool.Args["path"].(string)
-		if !ok {
-			return ToolCallResponse{Error: "缺少文件路径参数"}
-		}
+// errListLimit 条目数达到上限时用于停止遍历
+var errListLimit = errors.New("条目数达到上限")
 
-		// 安全检查：限制文件访问范围
-		if strings.Contains(path, "..") || strings.HasPrefix(path, "/") {
-			return ToolCallResponse{Error: "出于安全考虑，禁止访问上级目录或绝对路径"}
-		}
+// fileOperationHandlers 返回文件操作工具
+func fileOperationHandlers() []Handler {
+	return []Handler{
+		NewHandler(ToolDefinition{
+			Type:        TOOL_FILE_OPERATION,
+			Name:        "list",
+			Description: "以树形结构递归列出目录内容和文件大小，会跳过 .git、node_modules 和 .gitignore 中忽略的路径",
+			ReadOnly:    true,
+			Parameters: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"path":        {Type: "string", Description: "目录路径，相对于项目根目录，默认为项目根目录", MaxLength: maxPathLength},
+					"depth":       {Type: "integer", Description: "展开的目录层数，1 只列出当前目录，默认 2", Minimum: limit(1), Maximum: limit(maxListDepth)},
+					"max_entries": {Type: "integer", Description: "最多列出的条目数，默认 200", Minimum: limit(1), Maximum: limit(maxListEntries)},
+					"ignore":      {Type: "array", Description: "额外忽略的路径模式，语法与 .gitignore 相同", Items: &Schema{Type: "string"}},
+				},
+			},
+		}, listOperation),
+		NewHandler(ToolDefinition{
+			Type:        TOOL_FILE_OPERATION,
+			Name:        "read",
+			Description: "按行读取文件内容，每行前面带有行号和制表符，开头显示文件总行数。大文件可以通过 offset 和 limit 分段读取",
+			ReadOnly:    true,
+			Parameters: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"path":   {Type: "string", Description: "文件路径，相对于项目根目录", MinLength: 1, MaxLength: maxPathLength},
+					"offset": {Type: "integer", Description: "开始读取的行号，从 1 开始，默认 1", Minimum: limit(1)},
+					"limit":  {Type: "integer", Description: "最多读取的行数，默认 2000", Minimum: limit(1), Maximum: limit(maxReadLines)},
+				},
+				Required: []string{"path"},
+			},
+		}, readOperation),
+		NewHandler(ToolDefinition{
+			Type:        TOOL_FILE_OPERATION,
+			Name:        "search",
+			Description: "用正则表达式搜索项目文件内容，返回 path:line: text 格式的匹配行。会跳过 .git、node_modules、.gitignore 中忽略的文件和二进制文件",
+			ReadOnly:    true,
+			Parameters: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"pattern":          {Type: "string", Description: "Go 语法的正则表达式，逐行匹配", MinLength: 1},
+					"path":             {Type: "string", Description: "搜索的目录或文件，相对于项目根目录，默认为项目根目录", MaxLength: maxPathLength},
+					"include":          {Type: "array", Description: "只搜索匹配这些 glob 模式的文件，如 [\"*.go\", \"src/**/*.ts\"]，不含 / 的模式匹配文件名", Items: &Schema{Type: "string"}},
+					"ignore":           {Type: "array", Description: "额外忽略的路径模式，语法与 .gitignore 相同", Items: &Schema{Type: "string"}},
+					"context":          {Type: "integer", Description: "匹配行前后显示的上下文行数，默认 0", Minimum: limit(0), Maximum: limit(maxSearchContext)},
+					"max_results":      {Type: "integer", Description: "最多返回的匹配行数，默认 100", Minimum: limit(1), Maximum: limit(maxSearchResults)},
+					"case_insensitive": {Type: "boolean", Description: "是否忽略大小写，默认 false"},
+				},
+				Required: []string{"pattern"},
+			},
+		}, searchOperation),
+		NewHandler(ToolDefinition{
+			Type:        TOOL_FILE_OPERATION,
+			Name:        "glob",
+			Description: "按 glob 模式查找文件，返回匹配的文件路径，最近修改的排在前面。* 不跨越目录，** 匹配任意层目录，如 **/*.go",
+			ReadOnly:    true,
+			Parameters: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"pattern":     {Type: "string", Description: "glob 模式，相对于 path，如 **/*.go、src/*.ts", MinLength: 1, MaxLength: maxPathLength},
+					"path":        {Type: "string", Description: "查找的目录，相对于项目根目录，默认为项目根目录", MaxLength: maxPathLength},
+					"ignore":      {Type: "array", Description: "额外忽略的路径模式，语法与 .gitignore 相同", Items: &Schema{Type: "string"}},
+					"max_results": {Type: "integer", Description: "最多返回的文件数，默认 100", Minimum: limit(1), Maximum: limit(maxGlobResults)},
+				},
+				Required: []string{"pattern"},
+			},
+		}, globOperation),
+		NewHandler(ToolDefinition{
+			Type:        TOOL_FILE_OPERATION,
+			Name:        "write",
+			Description: "写入文件内容，会覆盖已有文件",
+			Serial:      true,
+			Parameters: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"path":    {Type: "string", Description: "文件路径，相对于项目根目录", MinLength: 1, MaxLength: maxPathLength},
+					"content": {Type: "string", Description: "完整的文件内容"},
+				},
+				Required: []string{"path", "content"},
+			},
+		}, writeOperation),
+		NewHandler(ToolDefinition{
+			Type:        TOOL_FILE_OPERATION,
+			Name:        "edit",
+			Description: "修改文件中的一段内容：把 old_string 替换为 new_string。old_string 必须与文件内容（包括缩进和空白，不包括 read 结果中的行号）完全一致，并且在文件中唯一，否则需要包含更多上下文或设置 replace_all",
+			Serial:      true,
+			Parameters: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"path":        {Type: "string", Description: "文件路径，相对于项目根目录", MinLength: 1, MaxLength: maxPathLength},
+					"old_string":  {Type: "string", Description: "要替换的原文", MinLength: 1},
+					"new_string":  {Type: "string", Description: "替换后的内容，为空时删除原文"},
+					"replace_all": {Type: "boolean", Description: "是否替换所有匹配，默认只允许唯一匹配"},
+				},
+				Required: []string{"path", "old_string", "new_string"},
+			},
+		}, editOperation),
+		NewHandler(ToolDefinition{
+			Type:        TOOL_FILE_OPERATION,
+			Name:        "patch",
+			Description: "应用 unified diff 格式的补丁，可以同时修改多个文件、新建或删除文件。上下文允许少量偏移和行尾空白差异；任何一个 hunk 无法匹配时整个补丁都不会应用",
+			Serial:      true,
+			Parameters: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"patch": {Type: "string", Description: "unified diff 内容，包含 --- / +++ 文件头和 @@ hunk，路径相对于项目根目录", MinLength: 1},
+				},
+				Required: []string{"patch"},
+			},
+		}, patchOperation),
+	}
+}
 
-		// 读取文件内容
-		result, err := readFile(path)
-		if err != nil {
-			return ToolCallResponse{Error: err.Error()}
-		}
-		return ToolCallResponse{Result: result}
+// checkWorkspacePath 检查路径是否位于项目目录内，禁止上级目录和绝对路径，action 为错误信息中的操作名称
+func checkWorkspacePath(path, action string) error {
+	if strings.Contains(path, "..") || strings.HasPrefix(path, "/") {
+		return fmt.Errorf("出于安全考虑，禁止%s上级目录或绝对路径: %s", action, path)
+	}
+	return nil
+}
 
-	case "write":
-		// 获取文件路径参数
-		path, ok := tool.Args["path"].(string)
-		if !ok {
-			return ToolCallResponse{Error: "缺少文件路径参数"}
-		}
+// listOperation 递归列出目录内容
+func listOperation(_ctx context.Context, args map[string]interface{}) ToolCallResponse {
+	// 获取目录参数，未指定时列出项目根目录
+	dir := stringArg(args, "path", ".")
+	if dir == "" {
+		dir = "."
+	}
 
-		// 获取文件内容参数
-		content, ok := tool.Args["content"].(string)
-		if !ok {
-			return ToolCallResponse{Error: "缺少文件内容参数"}
-		}
+	// 安全检查：限制目录访问范围
+	if err := checkWorkspacePath(dir, "访问"); err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
 
-		// 安全检查：限制文件写入范围
-		if strings.Contains(path, "..") || strings.HasPrefix(path, "/") {
-			return ToolCallResponse{Error: "出于安全考虑，禁止写入上级目录或绝对路径"}
-		}
+	// 列出目录内容
+	result, err := listDirectory(dir, intArg(args, "depth", defaultListDepth), intArg(args, "max_entries", defaultListEntries), stringsArg(args, "ignore"))
+	if err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+	return ToolCallResponse{Result: result}
+}
 
-		// 写入文件内容
-		err := writeFile(path, content)
-		if err != nil {
-			return ToolCallResponse{Error: err.Error()}
-		}
-		return ToolCallResponse{Result: fmt.Sprintf("成功写入文件 %s，内容长度: %d", path, len(content))}
+// readOperation 读取文件内容
+func readOperation(_ctx context.Context, args map[string]interface{}) ToolCallResponse {
+	// 获取文件路径参数
+	path := stringArg(args, "path", "")
 
-	default:
-		return ToolCallResponse{Error: fmt.Sprintf("未知的文件操作: %s", tool.Name)}
+	// 安全检查：限制文件访问范围
+	if err := checkWorkspacePath(path, "访问"); err != nil {
+		return ToolCallResponse{Error: err.Error()}
 	}
+
+	// 读取文件内容
+	result, err := readFile(path, intArg(args, "offset", 1), intArg(args, "limit", defaultReadLines))
+	if err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+	return ToolCallResponse{Result: result}
 }
 
-// listDirectory 列出目录内容
-func listDirectory(path string) (string, error) {
+// writeOperation 写入文件内容
+func writeOperation(_ctx context.Context, args map[string]interface{}) ToolCallResponse {
+	// 获取文件路径和内容参数
+	path := stringArg(args, "path", "")
+	content := stringArg(args, "content", "")
+
+	// 安全检查：限制文件写入范围
+	if err := checkWorkspacePath(path, "写入"); err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+
+	// 写入文件内容
+	err := writeFile(path, content)
+	if err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+	return ToolCallResponse{Result: fmt.Sprintf("成功写入文件 %s，内容长度: %d", path, len(content))}
+}
+
+// editOperation 替换文件中的一段内容
+func editOperation(_ctx context.Context, args map[string]interface{}) ToolCallResponse {
+	// 获取参数
+	path := stringArg(args, "path", "")
+	oldString := stringArg(args, "old_string", "")
+	newString := stringArg(args, "new_string", "")
+	replaceAll := boolArg(args, "replace_all", false)
+
+	// 安全检查：限制文件写入范围
+	if err := checkWorkspacePath(path, "写入"); err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+
+	// 编辑文件内容
+	result, err := editFile(path, oldString, newString, replaceAll)
+	if err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+	return ToolCallResponse{Result: result}
+}
+
+// listDirectory 递归列出目录内容，以缩进的树形结构显示，最多展开 depth 层、列出 maxEntries 个条目
+func listDirectory(path string, depth, maxEntries int, ignore []string) (string, error) {
 	// 确保路径存在
 	info, err := os.Stat(path)
 	if err != nil {
@@ -92,40 +249,73 @@ func listDirectory(path string) (string, error) {
 		return "", fmt.Errorf("%s 不是一个目录", path)
 	}
 
-	// 读取目录内容
-	files, err := ioutil.ReadDir(path)
-	if err != nil {
-		return "", fmt.Errorf("无法读取目录 %s: %v", path, err)
-	}
-
-	// 格式化输出
-	var result strings.Builder
-	result.WriteString(fmt.Sprintf("目录 %s 的内容:\n", path))
-	result.WriteString("名称\t类型\t大小\n")
-	result.WriteString("----\t----\t----\n")
-
-	for _, file := range files {
-		fileType := "文件"
-		if file.IsDir() {
-			fileType = "目录"
+	// 遍历目录，跳过忽略的路径，超过深度的目录只显示名称不展开
+	root := filepath.ToSlash(filepath.Clean(path))
+	var tree strings.Builder
+	entries, collapsed := 0, 0
+	truncated := false
+	err = walkWorkspace(root, ignore, func(rel string, info os.FileInfo) error {
+		if rel == root {
+			return nil
 		}
+		if entries >= maxEntries || tree.Len() >= maxListOutputBytes {
+			truncated = true
+			return errListLimit
+		}
+		entries++
 
-		size := file.Size()
-		sizeStr := fmt.Sprintf("%d bytes", size)
-		if size > 1024*1024 {
-			sizeStr = fmt.Sprintf("%.2f MB", float64(size)/1024/1024)
-		} else if size > 1024 {
-			sizeStr = fmt.Sprintf("%.2f KB", float64(size)/1024)
+		name := rel
+		if root != "." {
+			name = strings.TrimPrefix(rel, root+"/")
+		}
+		level := strings.Count(name, "/")
+		indent := strings.Repeat("  ", level)
+		if !info.IsDir() {
+			tree.WriteString(fmt.Sprintf("%s%s (%s)\n", indent, info.Name(), formatSize(info.Size())))
+			return nil
 		}
 
-		result.WriteString(fmt.Sprintf("%s\t%s\t%s\n", file.Name(), fileType, sizeStr))
+		if level+1 >= depth {
+			collapsed++
+			tree.WriteString(fmt.Sprintf("%s%s/ ...\n", indent, info.Name()))
+			return filepath.SkipDir
+		}
+		tree.WriteString(fmt.Sprintf("%s%s/\n", indent, info.Name()))
+		return nil
+	})
+	if err != nil && err != errListLimit {
+		return "", fmt.Errorf("无法读取目录 %s: %v", path, err)
 	}
 
+	// 格式化输出
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("目录 %s 的内容（%d 个条目）:\n", path, entries))
+	if entries == 0 {
+		result.WriteString("（空目录）\n")
+	}
+	result.WriteString(tree.String())
+	if truncated {
+		result.WriteString(fmt.Sprintf("[已达到显示上限，只列出了前 %d 个条目，可以列出子目录或减小 depth]\n", entries))
+	}
+	if collapsed > 0 {
+		result.WriteString(fmt.Sprintf("[%d 个以 ... 结尾的目录超过深度 %d 未展开，可以增大 depth 或列出该目录]\n", collapsed, depth))
+	}
 	return result.String(), nil
 }
 
-// readFile 读取文件内容
-func readFile(path string) (string, error) {
+// formatSize 把字节数格式化为便于阅读的大小
+func formatSize(size int64) string {
+	if size > 1024*1024 {
+		return fmt.Sprintf("%.2f MB", float64(size)/1024/1024)
+	} else if size > 1024 {
+		return fmt.Sprintf("%.2f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
+
+// readFile 按行读取文件内容，从第 offset 行（从 1 开始）开始最多读取 limit 行，每行带行号，
+// 单次读取超过 maxReadBytes 时截断，并在结果中说明文件总行数和如何继续读取
+func readFile(path string, offset, limit int) (string, error) {
 	// 确保路径存在
 	info, err := os.Stat(path)
 	if err != nil {
@@ -137,19 +327,73 @@ func readFile(path string) (string, error) {
 		return "", fmt.Errorf("%s 是一个目录，不是文件", path)
 	}
 
-	// 检查文件大小（限制为1MB）
-	const maxFileSize = 1024 * 1024 // 1MB
-	if info.Size() > maxFileSize {
-		return "", fmt.Errorf("文件 %s 太大 (%d bytes)，最大支持 1MB", path, info.Size())
-	}
-
-	// 读取文件内容
-	content, err := ioutil.ReadFile(path)
+	// 打开文件
+	file, err := os.Open(path)
 	if err != nil {
 		return "", fmt.Errorf("无法读取文件 %s: %v", path, err)
 	}
+	defer file.Close()
+
+	// 二进制文件没有可读的行
+	reader := bufio.NewReader(file)
+	if head, _ := reader.Peek(binaryDetectBytes); isBinary(head) {
+		return "", fmt.Errorf("%s 是二进制文件 (%s)，无法按行读取", path, formatSize(info.Size()))
+	}
+
+	// 逐行读取，跳过 offset 之前的行，读满 limit 行或达到字节上限后只统计总行数
+	var lines strings.Builder
+	total, last, size := 0, 0, 0
+	capped := false
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			total++
+			if total >= offset && total < offset+limit && !capped {
+				text := strings.TrimRight(line, "\r\n")
+				if runes := []rune(text); len(runes) > maxReadLineLength {
+					text = string(runes[:maxReadLineLength]) + "...（该行过长，已截断）"
+				}
+				entry := fmt.Sprintf("%6d\t%s\n", total, text)
+				if size+len(entry) > maxReadBytes && last > 0 {
+					capped = true
+				} else {
+					lines.WriteString(entry)
+					size += len(entry)
+					last = total
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("无法读取文件 %s: %v", path, err)
+		}
+	}
+
+	if total == 0 {
+		return fmt.Sprintf("文件 %s 为空", path), nil
+	}
+	if offset > total {
+		return "", fmt.Errorf("offset %d 超出了文件 %s 的总行数 %d", offset, path, total)
+	}
 
-	return string(content), nil
+	// 格式化输出，未读完时提示如何继续读取
+	var result strings.Builder
+	if offset == 1 && last == total {
+		result.WriteString(fmt.Sprintf("文件 %s 共 %d 行:\n", path, total))
+	} else {
+		result.WriteString(fmt.Sprintf("文件 %s 第 %d-%d 行，共 %d 行:\n", path, offset, last, total))
+	}
+	result.WriteString(lines.String())
+	if last < total {
+		reason := fmt.Sprintf("还有 %d 行未显示", total-last)
+		if capped {
+			reason = fmt.Sprintf("已达到单次读取 %s 的上限，%s", formatSize(maxReadBytes), reason)
+		}
+		result.WriteString(fmt.Sprintf("[%s，使用 offset=%d 继续读取]\n", reason, last+1))
+	}
+	return result.String(), nil
 }
 
 // writeFile 写入文件内容
@@ -168,3 +412,105 @@ func writeFile(path string, content string) error {
 
 	return nil
 }
+
+// maxEditMatches 编辑结果和错误信息中最多列出的匹配位置数
+const maxEditMatches = 10
+
+// editFile 把文件中的 oldString 替换为 newString，返回修改位置的说明
+func editFile(path, oldString, newString string, replaceAll bool) (string, error) {
+	if oldString == newString {
+		return "", fmt.Errorf("old_string 与 new_string 相同，文件无需修改")
+	}
+
+	// 确保路径存在
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("无法访问文件 %s: %v，创建新文件请使用 write", path, err)
+	}
+
+	// 检查是否是文件
+	if info.IsDir() {
+		return "", fmt.Errorf("%s 是一个目录，不是文件", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("无法读取文件 %s: %v", path, err)
+	}
+	content := string(data)
+
+	// 文件使用 CRLF 换行而参数使用 LF 时，按文件的换行方式匹配和替换
+	if !strings.Contains(content, oldString) && strings.Contains(content, "\r\n") && !strings.Contains(oldString, "\r\n") {
+		oldString = strings.ReplaceAll(oldString, "\n", "\r\n")
+		newString = strings.ReplaceAll(newString, "\n", "\r\n")
+	}
+
+	// 检查匹配情况
+	offsets := matchOffsets(content, oldString)
+	if len(offsets) == 0 {
+		return "", fmt.Errorf("在文件 %s 中没有找到 old_string，请先用 read 读取文件，确认 old_string 与文件内容（包括缩进、空白和换行）完全一致", path)
+	}
+	if len(offsets) > 1 && !replaceAll {
+		return "", fmt.Errorf("old_string 在文件 %s 中出现了 %d 次（第 %s 行），请包含更多上下文使其唯一，或设置 replace_all 为 true 替换全部",
+			path, len(offsets), joinLines(lineNumbers(content, offsets)))
+	}
+
+	// 替换并计算修改后每处内容所在的行
+	var edited strings.Builder
+	var ranges []string
+	last, newlines := 0, 0
+	for _, offset := range offsets {
+		edited.WriteString(content[last:offset])
+		newlines += strings.Count(content[last:offset], "\n")
+		start := newlines + 1
+		edited.WriteString(newString)
+		newlines += strings.Count(newString, "\n")
+		last = offset + len(oldString)
+
+		if newString == "" {
+			ranges = append(ranges, fmt.Sprintf("第 %d 行（已删除）", start))
+		} else if end := start + strings.Count(strings.TrimSuffix(newString, "\n"), "\n"); end > start {
+			ranges = append(ranges, fmt.Sprintf("第 %d-%d 行", start, end))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("第 %d 行", start))
+		}
+	}
+	edited.WriteString(content[last:])
+
+	// 保持原文件权限写回
+	if err := ioutil.WriteFile(path, []byte(edited.String()), info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("无法写入文件 %s: %v", path, err)
+	}
+
+	return fmt.Sprintf("成功编辑文件 %s，替换 %d 处，修改后的位置: %s", path, len(offsets), joinLines(ranges)), nil
+}
+
+// matchOffsets 返回 substr 在 content 中所有不重叠出现的位置
+func matchOffsets(content, substr string) []int {
+	var offsets []int
+	for start := 0; ; {
+		index := strings.Index(content[start:], substr)
+		if index < 0 {
+			return offsets
+		}
+		offsets = append(offsets, start+index)
+		start += index + len(substr)
+	}
+}
+
+// lineNumbers 返回每个位置所在的行号
+func lineNumbers(content string, offsets []int) []string {
+	lines := make([]string, len(offsets))
+	for i, offset := range offsets {
+		lines[i] = fmt.Sprintf("%d", strings.Count(content[:offset], "\n")+1)
+	}
+	return lines
+}
+
+// joinLines 用顿号连接行号或位置，过多时只列出前几个
+func joinLines(lines []string) string {
+	if len(lines) > maxEditMatches {
+		return strings.Join(lines[:maxEditMatches], "、") + fmt.Sprintf(" 等 %d 处", len(lines))
+	}
+	return strings.Join(lines, "、")
+}
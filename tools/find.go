@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 按模式查找文件的限制
+const (
+	defaultGlobResults = 100  // 默认最多返回的文件数
+	maxGlobResults     = 1000 // 最多返回的文件数上限
+)
+
+// globMatch 匹配的文件
+type globMatch struct {
+	path    string
+	modTime time.Time
+}
+
+// globOperation 按 glob 模式查找文件
+func globOperation(ctx context.Context, args map[string]interface{}) ToolCallResponse {
+	// 获取查找目录，未指定时从项目根目录开始查找
+	root := stringArg(args, "path", ".")
+	if root == "" {
+		root = "."
+	}
+	pattern := stringArg(args, "pattern", "")
+
+	// 安全检查：限制查找范围
+	for _, path := range []string{root, pattern} {
+		if err := checkWorkspacePath(path, "查找"); err != nil {
+			return ToolCallResponse{Error: err.Error()}
+		}
+	}
+
+	result, err := findFiles(ctx, root, pattern, stringsArg(args, "ignore"), intArg(args, "max_results", defaultGlobResults))
+	if err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+	return ToolCallResponse{Result: result}
+}
+
+// findFiles 查找 root 下相对路径匹配 pattern 的文件，按修改时间从新到旧排序，最多返回 maxResults 个
+func findFiles(ctx context.Context, root, pattern string, ignore []string, maxResults int) (string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("无法访问路径 %s: %v", root, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s 不是一个目录", root)
+	}
+
+	root = filepath.ToSlash(filepath.Clean(root))
+	pattern = strings.TrimPrefix(pattern, "./")
+	var matches []globMatch
+	err = walkWorkspace(root, ignore, func(rel string, info os.FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		name := rel
+		if root != "." {
+			name = strings.TrimPrefix(rel, root+"/")
+		}
+		if MatchGlob(pattern, name) {
+			matches = append(matches, globMatch{path: rel, modTime: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("查找失败: %v", err)
+	}
+
+	if len(matches) == 0 {
+		return fmt.Sprintf("在 %s 中没有找到匹配 %s 的文件", root, pattern), nil
+	}
+
+	// 最近修改的文件排在前面，修改时间相同时按路径排序
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].modTime.Equal(matches[j].modTime) {
+			return matches[i].modTime.After(matches[j].modTime)
+		}
+		return matches[i].path < matches[j].path
+	})
+
+	var result strings.Builder
+	if len(matches) > maxResults {
+		result.WriteString(fmt.Sprintf("找到 %d 个匹配的文件，只显示最近修改的 %d 个:\n", len(matches), maxResults))
+		matches = matches[:maxResults]
+	} else {
+		result.WriteString(fmt.Sprintf("找到 %d 个匹配的文件（按修改时间从新到旧）:\n", len(matches)))
+	}
+	for _, match := range matches {
+		result.WriteString(match.path + "\n")
+	}
+	return result.String(), nil
+}
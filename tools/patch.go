@@ -0,0 +1,575 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 补丁匹配的模糊程度限制
+const (
+	maxPatchContextFuzz = 2   // 每个 hunk 首尾最多忽略的上下文行数，每一侧至少保留一行
+	maxPatchOffset      = 200 // hunk 实际位置与 hunk 头中行号最多相差的行数
+	patchPreviewLines   = 3   // 被拒绝的 hunk 在说明中显示的原文行数
+)
+
+// patchFile 补丁中对一个文件的修改
+type patchFile struct {
+	oldPath string       // 原文件路径，新建文件时为空
+	newPath string       // 新文件路径，删除文件时为空
+	hunks   []*patchHunk // 按顺序排列的 hunk
+}
+
+// path 返回补丁修改的文件路径
+func (f *patchFile) path() string {
+	if f.newPath != "" {
+		return f.newPath
+	}
+	return f.oldPath
+}
+
+// patchHunk 补丁中的一个 hunk
+type patchHunk struct {
+	header    string      // @@ 行
+	oldStart  int         // 原文件中的起始行号，从1开始
+	lines     []patchLine // hunk 内容
+	noNewline bool        // 修改后的文件末尾没有换行
+}
+
+// patchLine hunk 中的一行
+type patchLine struct {
+	kind byte   // ' ' 上下文，'-' 删除，'+' 新增
+	text string // 不含前缀的行内容
+}
+
+// oldLines 返回 hunk 在原文件中对应的行（上下文和删除的行）
+func (h *patchHunk) oldLines() []string {
+	var lines []string
+	for _, line := range h.lines {
+		if line.kind != '+' {
+			lines = append(lines, line.text)
+		}
+	}
+	return lines
+}
+
+// trimContext 去掉首尾各最多 fuzz 行上下文，每一侧原有的上下文至少保留一行，避免 hunk 失去定位依据；
+// 返回去掉后的 hunk 和实际去掉的开头行数
+func (h *patchHunk) trimContext(fuzz int) ([]patchLine, int) {
+	lines := h.lines
+	leading := 0
+	for leading < fuzz && len(lines) > 1 && lines[0].kind == ' ' && lines[1].kind == ' ' {
+		lines = lines[1:]
+		leading++
+	}
+	for trailing := 0; trailing < fuzz && len(lines) > 1 && lines[len(lines)-1].kind == ' ' && lines[len(lines)-2].kind == ' '; trailing++ {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, leading
+}
+
+// patchOperation 应用 unified diff 补丁
+func patchOperation(_ctx context.Context, args map[string]interface{}) ToolCallResponse {
+	// 解析补丁
+	files, err := parsePatch(stringArg(args, "patch", ""))
+	if err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+
+	// 安全检查：补丁涉及的所有路径都必须在项目目录内
+	for _, file := range files {
+		for _, path := range []string{file.oldPath, file.newPath} {
+			if path == "" {
+				continue
+			}
+			if err := checkWorkspacePath(path, "写入"); err != nil {
+				return ToolCallResponse{Error: err.Error()}
+			}
+		}
+	}
+
+	// 应用补丁
+	result, err := applyPatch(files)
+	if err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+	return ToolCallResponse{Result: result}
+}
+
+// parsePatch 解析 unified diff，忽略 diff --git、index 等其他行
+func parsePatch(text string) ([]*patchFile, error) {
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), "\n")
+
+	var files []*patchFile
+	var file *patchFile
+	var hunk, last *patchHunk
+	oldLeft, newLeft := 0, 0 // 按 @@ 头中的行数，当前 hunk 还未读取的原文行数和新内容行数
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			file = &patchFile{
+				oldPath: parsePatchPath(line[4:], "a/"),
+				newPath: parsePatchPath(lines[i+1][4:], "b/"),
+			}
+			if file.oldPath == "" && file.newPath == "" {
+				return nil, fmt.Errorf("补丁第 %d 行: 原文件和新文件不能都是 /dev/null", i+1)
+			}
+			if file.oldPath != "" && file.newPath != "" && filepath.Clean(file.oldPath) != filepath.Clean(file.newPath) {
+				return nil, fmt.Errorf("补丁第 %d 行: 不支持重命名文件（%s -> %s）", i+1, file.oldPath, file.newPath)
+			}
+			files = append(files, file)
+			hunk, last = nil, nil
+			i++
+
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("补丁第 %d 行: hunk 之前缺少 --- / +++ 文件头", i+1)
+			}
+			oldStart, oldCount, newCount, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("补丁第 %d 行: %v", i+1, err)
+			}
+			hunk = &patchHunk{header: line, oldStart: oldStart}
+			last = hunk
+			oldLeft, newLeft = oldCount, newCount
+			file.hunks = append(file.hunks, hunk)
+
+		case hunk != nil && line != "" && (line[0] == ' ' || line[0] == '-' || line[0] == '+'):
+			// @@ 头中的行数不准确时，继续读取后面的内容行
+			hunk.lines = append(hunk.lines, patchLine{kind: line[0], text: line[1:]})
+			if line[0] != '+' {
+				oldLeft--
+			}
+			if line[0] != '-' {
+				newLeft--
+			}
+
+		case hunk != nil && line == "":
+			// 模型经常省略空行上下文前面的空格：@@ 头中还有未读取的行，或者后面还有内容行时视为空的上下文行，
+			// 否则是 hunk 之后多余的空行
+			if (oldLeft > 0 && newLeft > 0) || hunkContinues(lines[i+1:]) {
+				hunk.lines = append(hunk.lines, patchLine{kind: ' '})
+				oldLeft--
+				newLeft--
+			} else {
+				hunk = nil
+			}
+
+		case last != nil && strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" 作用于上一行，只关心修改后的文件
+			if n := len(last.lines); n > 0 && last.lines[n-1].kind != '-' {
+				last.noNewline = true
+			}
+
+		default:
+			hunk, last = nil, nil
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("补丁中没有找到 --- / +++ 文件头，请使用 unified diff 格式")
+	}
+	for _, file := range files {
+		if len(file.hunks) == 0 {
+			return nil, fmt.Errorf("文件 %s 的补丁中没有 hunk", file.path())
+		}
+	}
+	return files, nil
+}
+
+// hunkContinues 判断连续的空行之后是否还有属于当前 hunk 的内容行
+func hunkContinues(lines []string) bool {
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			return false
+		}
+		return line[0] == ' ' || line[0] == '-' || line[0] == '+'
+	}
+	return false
+}
+
+// parsePatchPath 解析文件头中的路径：去掉时间戳和 a/、b/ 前缀，/dev/null 返回空
+func parsePatchPath(header, prefix string) string {
+	path := strings.TrimSpace(header)
+	if index := strings.Index(path, "\t"); index >= 0 {
+		path = path[:index]
+	}
+	if path == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// parseHunkHeader 解析形如 @@ -10,5 +10,6 @@ 的 hunk 头，返回原文件起始行号、原文行数和新内容行数，省略的行数为 1
+func parseHunkHeader(header string) (int, int, int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, fmt.Errorf("无效的 hunk 头: %s", header)
+	}
+	oldStart, oldCount, err := parseHunkRange(fields[1][1:])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("无效的 hunk 头: %s", header)
+	}
+	_, newCount, err := parseHunkRange(fields[2][1:])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("无效的 hunk 头: %s", header)
+	}
+	return oldStart, oldCount, newCount, nil
+}
+
+// parseHunkRange 解析 hunk 头中形如 10,5 的范围，返回起始行号和行数
+func parseHunkRange(value string) (int, int, error) {
+	parts := strings.SplitN(value, ",", 2)
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	count := 1
+	if len(parts) == 2 {
+		if count, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// patchedFile 应用补丁过程中一个文件的状态
+type patchedFile struct {
+	path     string      // 文件路径
+	existed  bool        // 应用前文件是否存在
+	original []byte      // 应用前的内容
+	mode     os.FileMode // 文件权限
+	lines    []string    // 当前内容，按行拆分且不含换行符
+	crlf     bool        // 是否使用 CRLF 换行
+	newline  bool        // 末尾是否有换行
+	removed  bool        // 是否被删除
+}
+
+// content 返回文件当前的完整内容
+func (f *patchedFile) content() []byte {
+	if len(f.lines) == 0 {
+		return nil
+	}
+	text := strings.Join(f.lines, "\n")
+	if f.newline {
+		text += "\n"
+	}
+	if f.crlf {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+	return []byte(text)
+}
+
+// loadPatchedFile 读取补丁要修改的文件
+func loadPatchedFile(path string) (*patchedFile, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return &patchedFile{path: path, mode: 0644, newline: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法访问文件 %s: %v", path, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s 是一个目录，不是文件", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取文件 %s: %v", path, err)
+	}
+
+	text := string(data)
+	file := &patchedFile{path: path, existed: true, original: data, mode: info.Mode().Perm()}
+	file.crlf = strings.Contains(text, "\r\n")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	file.newline = text == "" || strings.HasSuffix(text, "\n")
+	if text = strings.TrimSuffix(text, "\n"); text != "" {
+		file.lines = strings.Split(text, "\n")
+	}
+	return file, nil
+}
+
+// applyPatch 在内存中应用所有文件的补丁，全部 hunk 都成功后才写入磁盘，否则不修改任何文件
+func applyPatch(files []*patchFile) (string, error) {
+	var report strings.Builder
+	var order []*patchedFile
+	states := make(map[string]*patchedFile)
+	failed := false
+
+	for _, file := range files {
+		key := filepath.Clean(file.path())
+		state, ok := states[key]
+		if !ok {
+			loaded, err := loadPatchedFile(file.path())
+			if err != nil {
+				return "", err
+			}
+			state = loaded
+			states[key] = state
+			order = append(order, state)
+		}
+
+		report.WriteString(file.path() + ":\n")
+		switch {
+		case file.oldPath == "" && (state.existed || len(state.lines) > 0) && !state.removed:
+			report.WriteString("  被拒绝: 补丁要新建文件，但文件已存在\n")
+			failed = true
+			continue
+		case file.oldPath != "" && (!state.existed || state.removed):
+			report.WriteString("  被拒绝: 文件不存在\n")
+			failed = true
+			continue
+		}
+
+		state.removed = false
+		if !applyHunks(state, file.hunks, &report) {
+			failed = true
+			continue
+		}
+
+		// 删除文件的补丁应用后内容应当为空
+		if file.newPath == "" {
+			if len(state.lines) > 0 {
+				report.WriteString("  被拒绝: 删除文件的补丁与文件内容不一致，应用后仍有剩余内容\n")
+				failed = true
+				continue
+			}
+			state.removed = true
+		}
+	}
+
+	if failed {
+		return "", fmt.Errorf("补丁未应用，所有文件均未修改。各 hunk 的结果如下，请根据最新的文件内容修正后重试:\n%s", strings.TrimRight(report.String(), "\n"))
+	}
+
+	if err := commitPatchedFiles(order); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("补丁已应用，共修改 %d 个文件:\n%s", len(order), strings.TrimRight(report.String(), "\n")), nil
+}
+
+// applyHunks 依次应用 hunk 并把每个 hunk 的结果写入报告，有 hunk 被拒绝时返回 false
+func applyHunks(file *patchedFile, hunks []*patchHunk, report *strings.Builder) bool {
+	ok := true
+	offset := 0  // 之前的 hunk 造成的行号偏移
+	minLine := 0 // 后面的 hunk 只能匹配在前面的 hunk 之后
+
+	for i, hunk := range hunks {
+		// 纯新增的 hunk 中起始行号表示插入到该行之后
+		start := hunk.oldStart - 1
+		if len(hunk.oldLines()) == 0 {
+			start = hunk.oldStart
+		}
+
+		lines, position, note, matched := matchHunk(file.lines, hunk, start+offset, start, minLine)
+		if !matched {
+			ok = false
+			report.WriteString(fmt.Sprintf("  hunk %d %s 被拒绝: 找不到匹配的内容", i+1, hunk.header))
+			if preview := hunk.oldLines(); len(preview) > 0 {
+				if len(preview) > patchPreviewLines {
+					preview = preview[:patchPreviewLines]
+				}
+				report.WriteString("，期望的原文开头:\n    " + strings.Join(preview, "\n    "))
+			}
+			report.WriteString("\n")
+			continue
+		}
+
+		var oldLines, newLines []string
+		for _, line := range lines {
+			if line.kind != '+' {
+				oldLines = append(oldLines, line.text)
+			}
+			if line.kind != '-' {
+				newLines = append(newLines, line.text)
+			}
+		}
+
+		updated := make([]string, 0, len(file.lines)-len(oldLines)+len(newLines))
+		updated = append(updated, file.lines[:position]...)
+		updated = append(updated, newLines...)
+		updated = append(updated, file.lines[position+len(oldLines):]...)
+		file.lines = updated
+		if hunk.noNewline {
+			file.newline = false
+		}
+
+		offset = position - start + len(newLines) - len(oldLines)
+		minLine = position + len(newLines)
+		report.WriteString(fmt.Sprintf("  hunk %d %s 已应用于第 %d 行%s\n", i+1, hunk.header, position+1, note))
+	}
+	return ok
+}
+
+// matchHunk 在 expected 附近查找 hunk 原文的位置，start 为 hunk 头中的位置，依次尝试精确匹配、忽略行尾空白、忽略首尾部分上下文，
+// 返回实际应用的 hunk 行、匹配位置和匹配方式说明
+func matchHunk(lines []string, hunk *patchHunk, expected, start, minLine int) ([]patchLine, int, string, bool) {
+	for fuzz := 0; fuzz <= maxPatchContextFuzz; fuzz++ {
+		trimmed, leading := hunk.trimContext(fuzz)
+		if fuzz > 0 && leading == 0 && len(trimmed) == len(hunk.lines) {
+			break // 没有可以忽略的上下文了
+		}
+
+		var old []string
+		for _, line := range trimmed {
+			if line.kind != '+' {
+				old = append(old, line.text)
+			}
+		}
+
+		for _, exact := range []bool{true, false} {
+			position, found := findLines(lines, old, expected+leading, minLine, exact)
+			if !found {
+				continue
+			}
+
+			var notes []string
+			if shift := position - leading - start; shift != 0 && len(old) > 0 {
+				notes = append(notes, fmt.Sprintf("偏移 %+d 行", shift))
+			}
+			if !exact {
+				notes = append(notes, "忽略行尾空白")
+			}
+			if len(trimmed) < len(hunk.lines) {
+				notes = append(notes, fmt.Sprintf("忽略了 %d 行上下文", len(hunk.lines)-len(trimmed)))
+			}
+			note := ""
+			if len(notes) > 0 {
+				note = "（" + strings.Join(notes, "，") + "）"
+			}
+			return trimmed, position, note, true
+		}
+	}
+	return nil, 0, "", false
+}
+
+// findLines 从 expected 开始向两侧查找 old 在 lines 中的位置，不早于 minLine，最多偏移 maxPatchOffset 行；
+// old 为空（纯新增）时直接使用 expected
+func findLines(lines, old []string, expected, minLine int, exact bool) (int, bool) {
+	if expected < minLine {
+		expected = minLine
+	}
+	if len(old) == 0 {
+		if expected > len(lines) {
+			expected = len(lines)
+		}
+		return expected, true
+	}
+
+	last := len(lines) - len(old)
+	for distance := 0; distance <= maxPatchOffset; distance++ {
+		before, after := expected-distance, expected+distance
+		if before < minLine && after > last {
+			return 0, false
+		}
+		if after >= minLine && after <= last && linesEqual(lines[after:after+len(old)], old, exact) {
+			return after, true
+		}
+		if distance > 0 && before >= minLine && before <= last && linesEqual(lines[before:before+len(old)], old, exact) {
+			return before, true
+		}
+	}
+	return 0, false
+}
+
+// linesEqual 逐行比较，exact 为 false 时忽略行尾空白
+func linesEqual(a, b []string, exact bool) bool {
+	for i := range a {
+		if exact {
+			if a[i] != b[i] {
+				return false
+			}
+		} else if strings.TrimRight(a[i], " \t") != strings.TrimRight(b[i], " \t") {
+			return false
+		}
+	}
+	return true
+}
+
+// commitPatchedFiles 把修改后的文件写入磁盘：先全部写入临时文件，再逐个替换或删除；
+// 中途失败时恢复已经替换的文件，保证不会留下只应用了一部分的补丁
+func commitPatchedFiles(files []*patchedFile) error {
+	temps := make([]string, len(files))
+	defer func() {
+		for _, temp := range temps {
+			if temp != "" {
+				os.Remove(temp)
+			}
+		}
+	}()
+
+	// 第一步：写入临时文件
+	for i, file := range files {
+		if file.removed {
+			continue
+		}
+		dir := filepath.Dir(file.path)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("补丁未应用: 无法创建目录 %s: %v", dir, err)
+		}
+		temp, err := writeTempFile(dir, filepath.Base(file.path), file.content(), file.mode)
+		if err != nil {
+			return fmt.Errorf("补丁未应用: %v", err)
+		}
+		temps[i] = temp
+	}
+
+	// 第二步：替换或删除，失败时回滚已完成的部分
+	for i, file := range files {
+		var err error
+		if file.removed {
+			err = os.Remove(file.path)
+		} else {
+			err = os.Rename(temps[i], file.path)
+			if err == nil {
+				temps[i] = ""
+			}
+		}
+		if err != nil {
+			rollbackPatchedFiles(files[:i])
+			return fmt.Errorf("补丁未应用: 无法写入文件 %s: %v", file.path, err)
+		}
+	}
+	return nil
+}
+
+// rollbackPatchedFiles 恢复已经写入的文件
+func rollbackPatchedFiles(files []*patchedFile) {
+	for _, file := range files {
+		if file.existed {
+			ioutil.WriteFile(file.path, file.original, file.mode)
+		} else {
+			os.Remove(file.path)
+		}
+	}
+}
+
+// writeTempFile 在目录中写入临时文件，返回临时文件路径
+func writeTempFile(dir, name string, content []byte, mode os.FileMode) (string, error) {
+	temp, err := ioutil.TempFile(dir, "."+name+".patch-")
+	if err != nil {
+		return "", fmt.Errorf("无法创建临时文件: %v", err)
+	}
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return "", fmt.Errorf("无法写入临时文件: %v", err)
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return "", fmt.Errorf("无法写入临时文件: %v", err)
+	}
+	if err := os.Chmod(temp.Name(), mode); err != nil {
+		os.Remove(temp.Name())
+		return "", fmt.Errorf("无法设置文件权限: %v", err)
+	}
+	return temp.Name(), nil
+}
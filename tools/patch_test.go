@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// inTempDir 在临时目录中运行测试，结束后回到原目录
+func inTempDir(t *testing.T) {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(dir) })
+}
+
+// applyPatchText 写入原文件、应用补丁并返回修改后的内容
+func applyPatchText(t *testing.T, original, patch string) string {
+	t.Helper()
+	if err := ioutil.WriteFile("f.txt", []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := parsePatch(patch)
+	if err != nil {
+		t.Fatalf("解析补丁失败: %v", err)
+	}
+	if _, err := applyPatch(files); err != nil {
+		t.Fatalf("应用补丁失败: %v", err)
+	}
+	data, err := ioutil.ReadFile("f.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApplyPatchKeepsBlankContextLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		patch    string
+		want     string
+	}{
+		{
+			name:     "结尾的空行上下文",
+			original: "a\n\nb\n",
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -2,1 +2,2 @@\n+x\n \n",
+			want:     "a\nx\n\nb\n",
+		},
+		{
+			name:     "省略空格的空行上下文",
+			original: "a\n\nb\n",
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,3 +1,4 @@\n a\n\n+x\n b\n",
+			want:     "a\n\nx\nb\n",
+		},
+		{
+			name:     "补丁末尾多余的空行",
+			original: "a\nb\n",
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\n\n",
+			want:     "a\nc\n",
+		},
+		{
+			name:     "行数不准确的 hunk",
+			original: "a\nb\nc\n",
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,1 +1,1 @@\n a\n-b\n+x\n c\n",
+			want:     "a\nx\nc\n",
+		},
+		{
+			name:     "末尾没有换行",
+			original: "a\nb\n",
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n",
+			want:     "a\nc",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t)
+			if got := applyPatchText(t, test.original, test.patch); got != test.want {
+				t.Errorf("修改后的内容为 %q，期望 %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchRejectsUnmatchedHunk(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		patch    string
+	}{
+		{
+			name:     "上下文全部不匹配",
+			original: "q\nr\ns\n",
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,3 @@\n a\n+x\n b\n",
+		},
+		{
+			name:     "只有一侧上下文匹配",
+			original: "a\nr\ns\n",
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,3 @@\n a\n+x\n b\n",
+		},
+		{
+			name:     "匹配位置超出偏移上限",
+			original: strings.Repeat("z\n", maxPatchOffset+10) + "a\nb\n",
+			patch:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,3 @@\n a\n+x\n b\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t)
+			if err := ioutil.WriteFile("f.txt", []byte(test.original), 0644); err != nil {
+				t.Fatal(err)
+			}
+			files, err := parsePatch(test.patch)
+			if err != nil {
+				t.Fatalf("解析补丁失败: %v", err)
+			}
+			if _, err := applyPatch(files); err == nil || !strings.Contains(err.Error(), "被拒绝") {
+				t.Fatalf("错误为 %v，期望 hunk 被拒绝", err)
+			}
+			if data, _ := ioutil.ReadFile("f.txt"); string(data) != test.original {
+				t.Errorf("文件被修改为 %q", data)
+			}
+		})
+	}
+}
+
+func TestApplyPatchMultiFileFailureLeavesFilesUnchanged(t *testing.T) {
+	inTempDir(t)
+	originals := map[string]string{"a.txt": "1\n2\n3\n", "b.txt": "x\ny\nz\n"}
+	for name, content := range originals {
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	patch := "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n 1\n-2\n+two\n 3\n" +
+		"--- a/b.txt\n+++ b/b.txt\n@@ -1,3 +1,3 @@\n x\n-missing\n+y2\n z\n" +
+		"--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1 @@\n+new\n"
+	files, err := parsePatch(patch)
+	if err != nil {
+		t.Fatalf("解析补丁失败: %v", err)
+	}
+	if _, err := applyPatch(files); err == nil || !strings.Contains(err.Error(), "所有文件均未修改") {
+		t.Fatalf("错误为 %v，期望补丁整体未应用", err)
+	}
+
+	for name, content := range originals {
+		if data, _ := ioutil.ReadFile(name); string(data) != content {
+			t.Errorf("%s 被修改为 %q", name, data)
+		}
+	}
+	if _, err := os.Stat("c.txt"); !os.IsNotExist(err) {
+		t.Errorf("c.txt 不应被创建: %v", err)
+	}
+}
+
+func TestApplyPatchFuzzyContext(t *testing.T) {
+	inTempDir(t)
+	original := "a\nb\nc\nd\ne\nf\ng\n"
+	patch := "--- a/f.txt\n+++ b/f.txt\n@@ -1,7 +1,7 @@\n A\n b\n c\n-d\n+D\n e\n f\n G\n"
+	if err := ioutil.WriteFile("f.txt", []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := parsePatch(patch)
+	if err != nil {
+		t.Fatalf("解析补丁失败: %v", err)
+	}
+	report, err := applyPatch(files)
+	if err != nil {
+		t.Fatalf("应用补丁失败: %v", err)
+	}
+	if !strings.Contains(report, "忽略了 2 行上下文") {
+		t.Errorf("报告为 %q，期望说明忽略了上下文", report)
+	}
+	if data, _ := ioutil.ReadFile("f.txt"); string(data) != "a\nb\nc\nD\ne\nf\ng\n" {
+		t.Errorf("修改后的内容为 %q", data)
+	}
+}
@@ -0,0 +1,318 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DEFAULT_POLICY_FILE 项目权限策略文件的默认位置，相对于项目根目录
+const DEFAULT_POLICY_FILE = ".simple-agent/policy.yaml"
+
+// 权限策略动作
+const (
+	POLICY_ALLOW = "allow" // 直接执行，不需要确认
+	POLICY_DENY  = "deny"  // 拒绝执行，并把命中的规则告诉模型
+	POLICY_ASK   = "ask"   // 执行前需要用户确认
+)
+
+// PolicyRule 一条权限规则，规则中所有非空条件都满足时命中
+type PolicyRule struct {
+	Name     string   `yaml:"name"`     // 规则名称，拒绝时告知模型
+	Action   string   `yaml:"action"`   // 动作：allow, deny, ask
+	Tool     string   `yaml:"tool"`     // 工具类型（file_operation）或具体工具（file_operation.write），为空或 * 时匹配所有工具
+	Paths    []string `yaml:"paths"`    // path 参数或补丁中文件路径的 glob 模式，不含 / 的模式匹配任意目录下的文件名
+	Commands []string `yaml:"commands"` // command 参数的匹配模式，* 匹配任意字符，不区分大小写
+	Reason   string   `yaml:"reason"`   // 规则说明
+}
+
+// Policy 工具调用的权限策略
+type Policy struct {
+	Rules    []PolicyRule `yaml:"rules"`    // 项目规则
+	Defaults *bool        `yaml:"defaults"` // 是否启用内置的默认规则，未设置时启用
+
+	source string // 策略文件路径，未加载文件时为空
+}
+
+// PolicyDecision 策略对一次工具调用的判定结果
+type PolicyDecision struct {
+	Action string      // 动作，没有规则命中时为空，由调用方按工具是否只读决定
+	Rule   *PolicyRule // 命中的规则
+}
+
+// Explain 生成拒绝执行时返回给模型的说明
+func (d PolicyDecision) Explain() string {
+	if d.Rule == nil {
+		return "该工具调用被权限策略拒绝"
+	}
+	message := fmt.Sprintf("该工具调用被权限策略拒绝，命中规则 %q", d.Rule.Name)
+	if d.Rule.Reason != "" {
+		message += "：" + d.Rule.Reason
+	}
+	return message + "。请不要重复该调用，换用其他方式完成任务或向用户说明"
+}
+
+// defaultPolicyRules 内置的默认规则
+func defaultPolicyRules() []PolicyRule {
+	return []PolicyRule{
+		{
+			Name:   "default-dangerous-commands",
+			Action: POLICY_DENY,
+			Tool:   TOOL_SHELL_COMMAND,
+			Commands: []string{
+				"*rm -rf*", "sudo", "sudo *", "* sudo", "* sudo *", "*/sudo", "*/sudo *",
+				"su", "su *", "* su", "* su *", "*/su", "*/su *",
+				"*chmod 777*", "*dd if=*", "*> /dev/*",
+			},
+			Reason: "出于安全考虑，禁止执行危险命令",
+		},
+	}
+}
+
+// DefaultPolicy 返回只包含内置默认规则的策略
+func DefaultPolicy() *Policy {
+	return &Policy{}
+}
+
+// LoadPolicy 加载权限策略文件，文件不存在时返回默认策略
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultPolicy(), nil
+		}
+		return nil, fmt.Errorf("无法读取权限策略文件 %s: %v", path, err)
+	}
+
+	policy := &Policy{source: path}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("权限策略文件 %s 格式错误: %v", path, err)
+	}
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rules[%d]", i)
+		}
+		switch rule.Action {
+		case POLICY_ALLOW, POLICY_DENY, POLICY_ASK:
+		default:
+			return nil, fmt.Errorf("权限策略文件 %s 中规则 %s 的 action 无效: %q，可选值: allow, deny, ask", path, rule.Name, rule.Action)
+		}
+		for _, pattern := range rule.Paths {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("权限策略文件 %s 中规则 %s 的路径模式无效: %q", path, rule.Name, pattern)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// Source 返回策略文件路径，使用默认策略时为空
+func (p *Policy) Source() string {
+	return p.source
+}
+
+// rules 返回生效的全部规则：项目规则在前，默认规则在后
+func (p *Policy) rules() []PolicyRule {
+	if p.Defaults != nil && !*p.Defaults {
+		return p.Rules
+	}
+	return append(append([]PolicyRule(nil), p.Rules...), defaultPolicyRules()...)
+}
+
+// Evaluate 判定一次工具调用：所有命中的规则中 deny 优先于 ask，ask 优先于 allow
+func (p *Policy) Evaluate(tool Tool) PolicyDecision {
+	var decision PolicyDecision
+	rules := p.rules()
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.matches(tool) {
+			continue
+		}
+		if decision.Rule == nil || actionPriority(rule.Action) > actionPriority(decision.Action) {
+			decision = PolicyDecision{Action: rule.Action, Rule: rule}
+		}
+	}
+	return decision
+}
+
+// policyContextKey 上下文中权限策略的键
+type policyContextKey struct{}
+
+// WithPolicy 把权限策略放入上下文，搜索等会读取多个文件的工具据此跳过策略限制的文件
+func WithPolicy(ctx context.Context, policy *Policy) context.Context {
+	return context.WithValue(ctx, policyContextKey{}, policy)
+}
+
+// policyFromContext 取出上下文中的权限策略，没有时返回 nil
+func policyFromContext(ctx context.Context) *Policy {
+	policy, _ := ctx.Value(policyContextKey{}).(*Policy)
+	return policy
+}
+
+// readRestricted 判断读取文件是否受策略限制：对该路径的 read 或 tool 调用命中 deny 或 ask 规则。
+// 一次调用读取多个文件时无法逐个确认，需要确认的文件也一并跳过
+func (p *Policy) readRestricted(tool, path string) bool {
+	for _, name := range []string{"read", tool} {
+		decision := p.Evaluate(Tool{Type: TOOL_FILE_OPERATION, Name: name, Args: map[string]interface{}{"path": path}})
+		if decision.Action == POLICY_DENY || decision.Action == POLICY_ASK {
+			return true
+		}
+	}
+	return false
+}
+
+// actionPriority 返回动作的优先级，数值越大越优先
+func actionPriority(action string) int {
+	switch action {
+	case POLICY_DENY:
+		return 3
+	case POLICY_ASK:
+		return 2
+	case POLICY_ALLOW:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// matches 判断规则是否命中工具调用
+func (r *PolicyRule) matches(tool Tool) bool {
+	if r.Tool != "" && r.Tool != "*" && r.Tool != tool.Type && r.Tool != toolKey(tool.Type, tool.Name) {
+		return false
+	}
+
+	if len(r.Paths) > 0 {
+		// 涉及多个路径时，deny 和 ask 规则只要有一个路径命中即生效，allow 规则要求所有路径都命中
+		paths := toolPaths(tool)
+		if len(paths) == 0 {
+			return false
+		}
+		matched := 0
+		for _, path := range paths {
+			if matchAnyPath(r.Paths, path) {
+				matched++
+			}
+		}
+		if matched == 0 || (r.Action == POLICY_ALLOW && matched < len(paths)) {
+			return false
+		}
+	}
+
+	if len(r.Commands) > 0 {
+		value, ok := tool.Args["command"].(string)
+		if !ok || !matchAnyCommand(r.Commands, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// toolPaths 返回工具调用涉及的路径：path 参数，以及 patch 参数中补丁修改的所有文件
+func toolPaths(tool Tool) []string {
+	var paths []string
+	if value, ok := tool.Args["path"].(string); ok {
+		paths = append(paths, value)
+	}
+	if text, ok := tool.Args["patch"].(string); ok {
+		// 无法解析的补丁不会被应用，不需要检查路径
+		files, err := parsePatch(text)
+		if err == nil {
+			for _, file := range files {
+				for _, path := range []string{file.oldPath, file.newPath} {
+					if path != "" {
+						paths = append(paths, path)
+					}
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// matchAnyPath 判断路径是否匹配任一 glob 模式，不含 / 的模式匹配任意目录下的文件名
+func matchAnyPath(patterns []string, value string) bool {
+	name := filepath.ToSlash(filepath.Clean(value))
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		if MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellSeparators 分隔 shell 命令的符号，匹配前在两侧补空格，使 ls;sudo rm 和 bash -c 'sudo ls' 中的 sudo 成为独立的词
+const shellSeparators = ";&|()`'\"{}<>\\"
+
+// safeRedirectTargets 重定向到这些设备不会修改任何数据，匹配命令前忽略，避免 2>/dev/null 命中 *> /dev/* 规则
+var safeRedirectTargets = map[string]bool{"/dev/null": true, "/dev/stdout": true, "/dev/stderr": true}
+
+// matchAnyCommand 判断命令是否匹配任一模式，命令和模式中的分隔符两侧补空格，连续的空白视为一个空格，
+// 命令中重定向到 /dev/null 等安全目标的部分不参与匹配
+func matchAnyCommand(patterns []string, value string) bool {
+	command := stripSafeRedirects(normalizeCommand(value))
+	for _, pattern := range patterns {
+		if matchWildcard(normalizeCommand(pattern), command) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeCommand 转为小写，在 shell 分隔符两侧补空格，并把连续的空白合并为一个空格
+func normalizeCommand(command string) string {
+	var spaced strings.Builder
+	for _, r := range strings.ToLower(command) {
+		if strings.ContainsRune(shellSeparators, r) {
+			spaced.WriteString(" " + string(r) + " ")
+			continue
+		}
+		spaced.WriteRune(r)
+	}
+	return strings.Join(strings.Fields(spaced.String()), " ")
+}
+
+// stripSafeRedirects 去掉已规范化的命令中形如 > /dev/null 的重定向
+func stripSafeRedirects(command string) string {
+	fields := strings.Split(command, " ")
+	kept := make([]string, 0, len(fields))
+	for i := 0; i < len(fields); i++ {
+		if fields[i] == ">" && i+1 < len(fields) && safeRedirectTargets[fields[i+1]] {
+			i++
+			continue
+		}
+		kept = append(kept, fields[i])
+	}
+	return strings.Join(kept, " ")
+}
+
+// matchWildcard 判断文本是否匹配只含 * 通配符的模式，* 可以匹配任意字符（包括 / 和空格）
+func matchWildcard(pattern, text string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == text
+	}
+
+	if !strings.HasPrefix(text, parts[0]) {
+		return false
+	}
+	text = text[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		index := strings.Index(text, part)
+		if index < 0 {
+			return false
+		}
+		text = text[index+len(part):]
+	}
+	return strings.HasSuffix(text, parts[len(parts)-1])
+}
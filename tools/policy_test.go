@@ -0,0 +1,123 @@
+package tools
+
+import "testing"
+
+// shellTool 构造一次 shell 命令调用
+func shellTool(command string) Tool {
+	return Tool{Type: TOOL_SHELL_COMMAND, Name: "execute", Args: map[string]interface{}{"command": command}}
+}
+
+func TestDefaultPolicyDeniesDangerousCommands(t *testing.T) {
+	commands := []string{
+		"sudo",
+		"sudo rm x",
+		"ls;sudo rm x",
+		"ls ; sudo rm x",
+		"a&&sudo b",
+		"a || sudo b",
+		"echo x|sudo tee /etc/y",
+		"bash -c 'sudo ls'",
+		`sh -c "sudo ls"`,
+		"echo $(sudo cat /etc/shadow)",
+		"echo `sudo id`",
+		"(sudo ls)",
+		"{ sudo ls; }",
+		"\\sudo ls",
+		"/usr/bin/sudo ls",
+		"env sudo ls",
+		"ls\nsudo ls",
+		"su",
+		"su root",
+		"ls;su -",
+		"echo x|su root",
+		"/bin/su root",
+		"rm -rf /",
+		"chmod 777 a",
+		"dd if=/dev/zero of=x",
+		"echo x >/dev/sda",
+		"ls >/dev/null; cat x > /dev/sda",
+		"echo x >/dev/nullx",
+	}
+
+	policy := DefaultPolicy()
+	for _, command := range commands {
+		if decision := policy.Evaluate(shellTool(command)); decision.Action != POLICY_DENY {
+			t.Errorf("命令 %q 应被拒绝，实际动作为 %q", command, decision.Action)
+		}
+	}
+}
+
+func TestDefaultPolicyAllowsOrdinaryCommands(t *testing.T) {
+	commands := []string{
+		"ls -la",
+		"go test ./...",
+		"echo pseudo",
+		"cat summary.txt",
+		"git status && git diff",
+		"grep -r sum .",
+		"go build ./... 2>/dev/null",
+		"ls >/dev/null",
+		"grep -q x f >/dev/null 2>&1",
+		"go vet ./... >> /dev/stderr",
+		"echo done > /dev/stdout",
+	}
+
+	policy := DefaultPolicy()
+	for _, command := range commands {
+		if decision := policy.Evaluate(shellTool(command)); decision.Action != "" {
+			t.Errorf("命令 %q 不应命中默认规则，实际动作为 %q", command, decision.Action)
+		}
+	}
+}
+
+func TestMatchAnyCommandNormalizesPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		command string
+		want    bool
+	}{
+		{"go test*", "go  test ./...", true},
+		{"GO TEST*", "go test", true},
+		{"git status&&*", "git status && git diff", true},
+		{"go vet*", "go test", false},
+	}
+
+	for _, test := range tests {
+		if got := matchAnyCommand([]string{test.pattern}, test.command); got != test.want {
+			t.Errorf("matchAnyCommand(%q, %q) = %v, 期望 %v", test.pattern, test.command, got, test.want)
+		}
+	}
+}
+
+func TestPolicyPathRulesApplyToPatchFiles(t *testing.T) {
+	policy := &Policy{Rules: []PolicyRule{
+		{Name: "no-secrets", Action: POLICY_DENY, Tool: TOOL_FILE_OPERATION, Paths: []string{".env", "secrets/**"}},
+		{Name: "docs", Action: POLICY_ALLOW, Tool: TOOL_FILE_OPERATION, Paths: []string{"docs/**"}},
+	}}
+	patch := func(paths ...string) Tool {
+		text := ""
+		for _, path := range paths {
+			text += "--- a/" + path + "\n+++ b/" + path + "\n@@ -1 +1 @@\n-a\n+b\n"
+		}
+		return Tool{Type: TOOL_FILE_OPERATION, Name: "patch", Args: map[string]interface{}{"patch": text}}
+	}
+
+	tests := []struct {
+		tool Tool
+		want string
+	}{
+		{patch(".env"), POLICY_DENY},
+		{patch("main.go", "secrets/key.pem"), POLICY_DENY},
+		{patch("docs/a.md", "config/.env"), POLICY_DENY},
+		{patch("docs/a.md", "docs/b.md"), POLICY_ALLOW},
+		{patch("docs/a.md", "main.go"), ""},
+		{patch("main.go"), ""},
+		{Tool{Type: TOOL_FILE_OPERATION, Name: "patch", Args: map[string]interface{}{"patch": "--- /dev/null\n+++ b/secrets/new\n@@ -0,0 +1 @@\n+x\n"}}, POLICY_DENY},
+	}
+
+	for i, test := range tests {
+		if got := policy.Evaluate(test.tool).Action; got != test.want {
+			t.Errorf("用例 %d: 动作为 %q，期望 %q", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"simple-agent/logger"
+
+	"go.uber.org/zap"
+)
+
+// ToolDefinition 工具定义，描述一个工具操作及其参数
+type ToolDefinition struct {
+	Type        string  // 工具类型
+	Name        string  // 工具名称
+	Description string  // 工具说明
+	Parameters  *Schema // 参数 Schema
+	Serial      bool    // 是否必须串行执行（如写文件、执行命令），不与其他调用并行
+	ReadOnly    bool    // 是否只读，只读工具执行前不需要用户确认
+}
+
+// Key 返回工具的唯一标识，形如 file_operation.read
+func (d ToolDefinition) Key() string {
+	return toolKey(d.Type, d.Name)
+}
+
+// FunctionName 返回工具在原生函数调用中使用的函数名
+func (d ToolDefinition) FunctionName() string {
+	return FunctionName(d.Type, d.Name)
+}
+
+// toolKey 组合工具类型和名称
+func toolKey(toolType, toolName string) string {
+	return toolType + "." + toolName
+}
+
+// functionNameSeparator 函数名中工具类型与工具名称的分隔符
+const functionNameSeparator = "__"
+
+// FunctionName 将工具类型和名称组合为函数名，如 file_operation__read
+func FunctionName(toolType, toolName string) string {
+	return toolType + functionNameSeparator + toolName
+}
+
+// ParseFunctionName 将函数名拆分为工具类型和名称
+func ParseFunctionName(functionName string) (string, string, bool) {
+	index := strings.LastIndex(functionName, functionNameSeparator)
+	if index <= 0 {
+		return "", "", false
+	}
+	return functionName[:index], functionName[index+len(functionNameSeparator):], true
+}
+
+// Handler 工具处理器，每个工具操作实现一个并注册到 Registry
+type Handler interface {
+	// Definition 返回工具定义
+	Definition() ToolDefinition
+	// Execute 执行工具
+	Execute(ctx context.Context, args map[string]interface{}) ToolCallResponse
+}
+
+// funcHandler 由定义和函数组成的 Handler
+type funcHandler struct {
+	definition ToolDefinition
+	execute    func(ctx context.Context, args map[string]interface{}) ToolCallResponse
+}
+
+// NewHandler 用工具定义和执行函数创建 Handler
+func NewHandler(definition ToolDefinition, execute func(ctx context.Context, args map[string]interface{}) ToolCallResponse) Handler {
+	return &funcHandler{definition: definition, execute: execute}
+}
+
+// Definition 实现 Handler 接口
+func (h *funcHandler) Definition() ToolDefinition {
+	return h.definition
+}
+
+// Execute 实现 Handler 接口
+func (h *funcHandler) Execute(ctx context.Context, args map[string]interface{}) ToolCallResponse {
+	return h.execute(ctx, args)
+}
+
+// Registry 工具注册表，负责工具的查找、列举和调度
+type Registry struct {
+	handlers map[string]Handler // 按 type.name 索引
+	order    []string           // 注册顺序
+}
+
+// NewRegistry 创建一个空的工具注册表
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler)}
+}
+
+// DefaultRegistry 创建包含所有内置工具的注册表
+func DefaultRegistry() *Registry {
+	registry := NewRegistry()
+	for _, handler := range fileOperationHandlers() {
+		registry.Register(handler)
+	}
+	for _, handler := range shellCommandHandlers() {
+		registry.Register(handler)
+	}
+	return registry
+}
+
+// Register 注册一个工具，重复注册同名工具属于编程错误，会直接 panic
+func (r *Registry) Register(handler Handler) {
+	key := handler.Definition().Key()
+	if _, exists := r.handlers[key]; exists {
+		panic(fmt.Sprintf("工具 %s 重复注册", key))
+	}
+	r.handlers[key] = handler
+	r.order = append(r.order, key)
+}
+
+// Lookup 按工具类型和名称查找工具
+func (r *Registry) Lookup(toolType, toolName string) (Handler, bool) {
+	handler, ok := r.handlers[toolKey(toolType, toolName)]
+	return handler, ok
+}
+
+// IsSerial 判断工具调用是否必须串行执行，未知工具不需要串行
+func (r *Registry) IsSerial(tool Tool) bool {
+	handler, ok := r.Lookup(tool.Type, tool.Name)
+	return ok && handler.Definition().Serial
+}
+
+// Definitions 按注册顺序返回所有工具定义
+func (r *Registry) Definitions() []ToolDefinition {
+	definitions := make([]ToolDefinition, 0, len(r.order))
+	for _, key := range r.order {
+		definitions = append(definitions, r.handlers[key].Definition())
+	}
+	return definitions
+}
+
+// Filter 返回只包含启用工具的新注册表，enabled 中每项可以是工具类型（file_operation）或具体工具（file_operation.read）
+func (r *Registry) Filter(enabled []string) (*Registry, error) {
+	filtered := NewRegistry()
+	for _, name := range enabled {
+		matched := false
+		for _, key := range r.order {
+			definition := r.handlers[key].Definition()
+			if name != definition.Type && name != key {
+				continue
+			}
+			matched = true
+			if _, exists := filtered.handlers[key]; !exists {
+				filtered.Register(r.handlers[key])
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("未知的工具: %s", name)
+		}
+	}
+	return filtered, nil
+}
+
+// Execute 按 Schema 校验参数后调度工具调用到对应的 Handler，Handler 可以直接使用已校验的参数
+func (r *Registry) Execute(ctx context.Context, tool Tool) ToolCallResponse {
+	handler, err := r.Resolve(tool)
+	if err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+
+	args := tool.Args
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if err := ValidateArgs(handler.Definition(), args); err != nil {
+		logger.Warn("工具参数校验失败", zap.String("工具", tool.Type+"."+tool.Name), zap.Strings("问题", err.Problems))
+		return ToolCallResponse{Error: err.Error()}
+	}
+	return handler.Execute(ctx, args)
+}
+
+// Resolve 查找工具调用对应的 Handler，找不到时返回列出可用工具的错误
+func (r *Registry) Resolve(tool Tool) (Handler, error) {
+	if handler, ok := r.Lookup(tool.Type, tool.Name); ok {
+		return handler, nil
+	}
+	return nil, r.unknownToolError(tool)
+}
+
+// unknownToolError 生成未知工具的错误信息，列出可用的工具
+func (r *Registry) unknownToolError(tool Tool) error {
+	available := make([]string, 0, len(r.order))
+	typeKnown := false
+	for _, key := range r.order {
+		available = append(available, key)
+		if r.handlers[key].Definition().Type == tool.Type {
+			typeKnown = true
+		}
+	}
+	sort.Strings(available)
+
+	if typeKnown {
+		return fmt.Errorf("未知的工具操作: %s.%s，可用工具: %s", tool.Type, tool.Name, strings.Join(available, ", "))
+	}
+	return fmt.Errorf("未知的工具类型: %s，可用工具: %s", tool.Type, strings.Join(available, ", "))
+}
+
+// Describe 生成工具列表的文字说明，用于系统提示词和 /tool 命令
+func (r *Registry) Describe() string {
+	var description strings.Builder
+	for i, definition := range r.Definitions() {
+		description.WriteString(fmt.Sprintf("%d. %s (type: %q, name: %q)\n", i+1, definition.Description, definition.Type, definition.Name))
+		description.WriteString(fmt.Sprintf("   参数：%s\n", describeParameters(definition.Parameters)))
+	}
+	return strings.TrimRight(description.String(), "\n")
+}
+
+// describeParameters 将参数 Schema 渲染为形如 {"path": "文件路径"} 的示例
+func describeParameters(schema *Schema) string {
+	if schema == nil || len(schema.Properties) == 0 {
+		return "{}"
+	}
+
+	required := make(map[string]bool, len(schema.Required))
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	// 必填参数按声明顺序在前，可选参数按名称排序在后，保证提示词稳定
+	names := append([]string(nil), schema.Required...)
+	var optional []string
+	for name := range schema.Properties {
+		if !required[name] {
+			optional = append(optional, name)
+		}
+	}
+	sort.Strings(optional)
+	names = append(names, optional...)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		hint := schema.Properties[name].Description
+		if !required[name] {
+			hint += "（可选）"
+		}
+		key, _ := json.Marshal(name)
+		value, _ := json.Marshal(hint)
+		parts = append(parts, fmt.Sprintf("%s: %s", key, value))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
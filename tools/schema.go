@@ -0,0 +1,20 @@
+package tools
+
+// Schema 工具参数的 JSON Schema 描述，可直接序列化后发送给模型，执行前也用于校验参数
+type Schema struct {
+	Type        string             `json:"type"`                  // 参数类型：object, string, integer, number, boolean, array
+	Description string             `json:"description,omitempty"` // 参数说明
+	Properties  map[string]*Schema `json:"properties,omitempty"`  // 对象属性
+	Required    []string           `json:"required,omitempty"`    // 必填属性
+	Enum        []string           `json:"enum,omitempty"`        // 字符串可选值
+	MinLength   int                `json:"minLength,omitempty"`   // 字符串最小长度（字符数）
+	MaxLength   int                `json:"maxLength,omitempty"`   // 字符串最大长度（字符数），0表示不限制
+	Minimum     *float64           `json:"minimum,omitempty"`     // 数值最小值
+	Maximum     *float64           `json:"maximum,omitempty"`     // 数值最大值
+	Items       *Schema            `json:"items,omitempty"`       // 数组元素
+}
+
+// limit 返回数值边界的指针，用于设置 Minimum 和 Maximum
+func limit(value float64) *float64 {
+	return &value
+}
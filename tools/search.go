@@ -0,0 +1,224 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// 内容搜索的限制
+const (
+	defaultSearchResults = 100         // 默认最多返回的匹配行数
+	maxSearchResults     = 500         // 最多返回的匹配行数上限
+	maxSearchContext     = 10          // 匹配行前后最多显示的上下文行数
+	maxSearchFileSize    = 1024 * 1024 // 跳过超过该大小的文件
+	maxSearchLineLength  = 300         // 结果中每行最多显示的字符数
+	binaryDetectBytes    = 8000        // 检查文件开头多少字节判断是否为二进制文件
+	searchSeparator      = "--"        // 不相邻的匹配片段之间的分隔行
+	searchTruncatedHint  = "缩小 path 或 include 范围，或者使用更具体的正则表达式"
+)
+
+// errSearchLimit 匹配数达到上限时用于停止遍历
+var errSearchLimit = errors.New("匹配数达到上限")
+
+// searchOptions 内容搜索的参数
+type searchOptions struct {
+	pattern    *regexp.Regexp
+	root       string
+	include    []string
+	ignore     []string
+	context    int
+	maxResults int
+}
+
+// searchOperation 在项目目录中搜索匹配正则表达式的行
+func searchOperation(ctx context.Context, args map[string]interface{}) ToolCallResponse {
+	// 获取搜索目录，未指定时搜索项目根目录
+	root := stringArg(args, "path", ".")
+	if root == "" {
+		root = "."
+	}
+
+	// 安全检查：限制搜索范围
+	if err := checkWorkspacePath(root, "搜索"); err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+
+	// 编译正则表达式
+	expr := stringArg(args, "pattern", "")
+	if boolArg(args, "case_insensitive", false) {
+		expr = "(?i)" + expr
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return ToolCallResponse{Error: fmt.Sprintf("正则表达式无效: %v", err)}
+	}
+
+	result, err := searchFiles(ctx, searchOptions{
+		pattern:    pattern,
+		root:       root,
+		include:    stringsArg(args, "include"),
+		ignore:     stringsArg(args, "ignore"),
+		context:    intArg(args, "context", 0),
+		maxResults: intArg(args, "max_results", defaultSearchResults),
+	})
+	if err != nil {
+		return ToolCallResponse{Error: err.Error()}
+	}
+	return ToolCallResponse{Result: result}
+}
+
+// searchFiles 遍历目录搜索匹配的行，每个匹配输出为 path:line: text，上下文行输出为 path-line- text
+func searchFiles(ctx context.Context, options searchOptions) (string, error) {
+	if _, err := os.Stat(options.root); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("路径 %s 不存在", options.root)
+		}
+		return "", fmt.Errorf("无法访问 %s: %v", options.root, err)
+	}
+
+	var output []string
+	matches, files, restricted := 0, 0, 0
+	policy := policyFromContext(ctx)
+	err := walkWorkspace(options.root, options.ignore, func(rel string, info os.FileInfo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() || !info.Mode().IsRegular() || info.Size() > maxSearchFileSize {
+			return nil
+		}
+		if !matchInclude(options.include, rel) {
+			return nil
+		}
+		if policy != nil && policy.readRestricted("search", rel) {
+			restricted++
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(rel)
+		if err != nil || isBinary(data) {
+			return nil
+		}
+
+		lines, count := searchLines(rel, data, options, options.maxResults-matches)
+		if count == 0 {
+			return nil
+		}
+		if options.context > 0 && len(output) > 0 {
+			output = append(output, searchSeparator)
+		}
+		output = append(output, lines...)
+		matches += count
+		files++
+		if matches >= options.maxResults {
+			return errSearchLimit
+		}
+		return nil
+	})
+	if err != nil && err != errSearchLimit {
+		return "", fmt.Errorf("搜索失败: %v", err)
+	}
+
+	note := ""
+	if restricted > 0 {
+		note = fmt.Sprintf("\n[%d 个文件受权限策略限制，没有搜索]", restricted)
+	}
+	if matches == 0 {
+		return fmt.Sprintf("在 %s 中没有找到匹配 %s 的内容", options.root, options.pattern) + note, nil
+	}
+
+	header := fmt.Sprintf("在 %d 个文件中找到 %d 处匹配:", files, matches)
+	if err == errSearchLimit {
+		header = fmt.Sprintf("已达到 %d 处匹配的上限，结果不完整（%s）:", options.maxResults, searchTruncatedHint)
+	}
+	return header + "\n" + strings.Join(output, "\n") + note, nil
+}
+
+// searchLines 在一个文件中搜索匹配的行，最多返回 limit 处匹配，返回输出行和匹配数
+func searchLines(rel string, data []byte, options searchOptions, limit int) ([]string, int) {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	var output []string
+	count := 0
+	last := -1 // 已输出的最后一行
+	for i, line := range lines {
+		if count >= limit {
+			break
+		}
+		if !options.pattern.MatchString(line) {
+			continue
+		}
+		count++
+
+		// 输出上下文，与上一段不相邻时插入分隔行
+		start := i - options.context
+		if start <= last {
+			start = last + 1
+		} else if last >= 0 && options.context > 0 {
+			output = append(output, searchSeparator)
+		}
+		if start < 0 {
+			start = 0
+		}
+		for j := start; j < i; j++ {
+			output = append(output, formatSearchLine(rel, j, lines[j], "-"))
+		}
+		output = append(output, formatSearchLine(rel, i, line, ":"))
+		last = i
+
+		// 输出之后的上下文，遇到下一处匹配时交给下一次循环处理
+		for j := i + 1; j <= i+options.context && j < len(lines); j++ {
+			if options.pattern.MatchString(lines[j]) && count < limit {
+				break
+			}
+			output = append(output, formatSearchLine(rel, j, lines[j], "-"))
+			last = j
+		}
+	}
+	return output, count
+}
+
+// formatSearchLine 格式化一行结果，index 从 0 开始，separator 区分匹配行（:）和上下文行（-）
+func formatSearchLine(rel string, index int, line, separator string) string {
+	runes := []rune(line)
+	if len(runes) > maxSearchLineLength {
+		line = string(runes[:maxSearchLineLength]) + "..."
+	}
+	return fmt.Sprintf("%s%s%d%s %s", rel, separator, index+1, separator, line)
+}
+
+// matchInclude 判断文件是否匹配任意一个 include 模式，不含 / 的模式匹配文件名，未指定时匹配所有文件
+func matchInclude(include []string, rel string) bool {
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if !strings.Contains(pattern, "/") {
+			if MatchGlob(pattern, path.Base(rel)) {
+				return true
+			}
+			continue
+		}
+		if MatchGlob(strings.TrimPrefix(pattern, "./"), rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBinary 根据开头是否包含空字节判断是否为二进制文件
+func isBinary(data []byte) bool {
+	if len(data) > binaryDetectBytes {
+		data = data[:binaryDetectBytes]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
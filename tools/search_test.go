@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSearchSkipsFilesRestrictedByPolicy(t *testing.T) {
+	inTempDir(t)
+	files := map[string]string{
+		"main.go":           "token := load()\n",
+		"secrets/key.txt":   "token=abc123\n",
+		"config/.env":       "TOKEN=xyz\n",
+		"docs/notes.md":     "token rotation\n",
+		"docs/private/a.md": "token in private docs\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policy := &Policy{Rules: []PolicyRule{
+		{Name: "no-secrets", Action: POLICY_DENY, Tool: "file_operation.read", Paths: []string{"secrets/**", ".env"}},
+		{Name: "private-docs", Action: POLICY_ASK, Tool: "file_operation.search", Paths: []string{"docs/private/**"}},
+	}}
+	ctx := WithPolicy(context.Background(), policy)
+
+	for _, root := range []string{".", "secrets", "secrets/key.txt"} {
+		response := searchOperation(ctx, map[string]interface{}{"pattern": "(?i)token", "path": root})
+		if response.Error != "" {
+			t.Fatalf("搜索 %s 失败: %s", root, response.Error)
+		}
+		for _, leaked := range []string{"abc123", "xyz", "private docs"} {
+			if strings.Contains(response.Result, leaked) {
+				t.Errorf("搜索 %s 的结果包含受限文件的内容 %q:\n%s", root, leaked, response.Result)
+			}
+		}
+		if !strings.Contains(response.Result, "受权限策略限制") {
+			t.Errorf("搜索 %s 的结果没有说明跳过的文件:\n%s", root, response.Result)
+		}
+	}
+
+	response := searchOperation(ctx, map[string]interface{}{"pattern": "token"})
+	for _, want := range []string{"main.go:1:", "docs/notes.md:1:"} {
+		if !strings.Contains(response.Result, want) {
+			t.Errorf("搜索结果缺少 %s:\n%s", want, response.Result)
+		}
+	}
+}
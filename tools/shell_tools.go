@@ -2,37 +2,46 @@ package tools
 
 import (
 	"context"
-	"fmt"
 	"os/exec"
-	"strings"
 	"time"
 )
 
-// ExecuteShellCommand 执行Shell命令
-func ExecuteShellCommand(tool Tool) ToolCallResponse {
-	// 获取命令参数
-	cmdStr, ok := tool.Args["command"].(string)
-	if !ok {
-		return ToolCallResponse{Error: "缺少命令参数"}
+// shellCommandHandlers 返回Shell命令工具
+func shellCommandHandlers() []Handler {
+	return []Handler{
+		NewHandler(ToolDefinition{
+			Type:        TOOL_SHELL_COMMAND,
+			Name:        "execute",
+			Description: "在项目根目录执行Shell命令，超时时间30秒",
+			Serial:      true,
+			Parameters: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"command": {Type: "string", Description: "要执行的命令", MinLength: 1, MaxLength: 10000},
+				},
+				Required: []string{"command"},
+			},
+		}, executeCommand),
 	}
+}
 
-	// 安全检查：禁止执行危险命令
-	dangerousCommands := []string{"rm -rf", "sudo", "su", "chmod 777", "dd if=", "> /dev/"}
-	for _, dangerous := range dangerousCommands {
-		if strings.Contains(strings.ToLower(cmdStr), strings.ToLower(dangerous)) {
-			return ToolCallResponse{Error: fmt.Sprintf("出于安全考虑，禁止执行命令: %s", cmdStr)}
-		}
-	}
+// executeCommand 执行Shell命令
+func executeCommand(ctx context.Context, args map[string]interface{}) ToolCallResponse {
+	// 获取命令参数
+	cmdStr := stringArg(args, "command", "")
 
-	// 设置超时上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// 在调用方上下文上设置超时，调用方取消时命令会被一并终止
+	cmdCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
-	cmd := exec.CommandContext(ctx, "sh", "-c", cmdStr)
+	cmd := exec.CommandContext(cmdCtx, "sh", "-c", cmdStr)
 
 	// 获取输出
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
+		if ctx.Err() != nil {
+			return ToolCallResponse{Result: string(output), Error: "命令执行已取消"}
+		}
+		if cmdCtx.Err() == context.DeadlineExceeded {
 			return ToolCallResponse{Error: "命令执行超时"}
 		}
 		return ToolCallResponse{
@@ -42,4 +51,4 @@ func ExecuteShellCommand(tool Tool) ToolCallResponse {
 	}
 
 	return ToolCallResponse{Result: string(output)}
-} 
\ No newline at end of file
+}
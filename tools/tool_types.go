@@ -2,10 +2,11 @@ package tools
 
 // Tool 工具结构体
 type Tool struct {
-	Type    string                 `json:"type"`    // 工具类型
-	Name    string                 `json:"name"`    // 工具名称
-	Args    map[string]interface{} `json:"args"`    // 工具参数
-	Thought string                 `json:"thought"` // 工具思考过程
+	ID      string                 `json:"id,omitempty"` // 原生函数调用的调用ID
+	Type    string                 `json:"type"`         // 工具类型
+	Name    string                 `json:"name"`         // 工具名称
+	Args    map[string]interface{} `json:"args"`         // 工具参数
+	Thought string                 `json:"thought"`      // 工具思考过程
 }
 
 // ToolCallResponse 工具调用响应
@@ -18,4 +19,4 @@ type ToolCallResponse struct {
 const (
 	TOOL_FILE_OPERATION = "file_operation" // 文件操作工具
 	TOOL_SHELL_COMMAND  = "shell_command"  // Shell命令工具
-) 
\ No newline at end of file
+)
@@ -5,200 +5,130 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"simple-agent/logger"
 
 	"go.uber.org/zap"
 )
 
-// ToolExecutor 工具执行器接口
-type ToolExecutor interface {
-	ExecuteFileOperation(tool Tool) ToolCallResponse
-	ExecuteShellCommand(tool Tool) ToolCallResponse
-}
-
-// ExecuteTool 执行单个工具调用
-func ExecuteTool(_ctx context.Context, tool Tool, executor ToolExecutor) ToolCallResponse {
-	switch tool.Type {
-	case TOOL_FILE_OPERATION:
-		return executor.ExecuteFileOperation(tool)
-
-	case TOOL_SHELL_COMMAND:
-		return executor.ExecuteShellCommand(tool)
+// DEFAULT_TOOL_WORKERS 并行执行工具调用时的默认并发数
+const DEFAULT_TOOL_WORKERS = 4
 
-	default:
-		return ToolCallResponse{
-			Error: fmt.Sprintf("未知的工具类型: %s", tool.Type),
-		}
+// ExecuteTool 执行单个工具调用，ctx 取消后不再执行
+func ExecuteTool(ctx context.Context, tool Tool, registry *Registry) ToolCallResponse {
+	if err := ctx.Err(); err != nil {
+		return ToolCallResponse{Error: fmt.Sprintf("工具调用已取消: %v", err)}
 	}
+
+	start := time.Now()
+	response := registry.Execute(ctx, tool)
+	logger.InfoToFile("工具调用完成",
+		zap.String("工具", toolKey(tool.Type, tool.Name)),
+		zap.Duration("耗时", time.Since(start)),
+		zap.Bool("失败", response.Error != ""))
+	return response
 }
 
-// ExecuteTools 执行多个工具调用
-func ExecuteTools(ctx context.Context, tools []Tool, executor ToolExecutor) []ToolCallResponse {
+// ExecuteTools 执行多个工具调用，结果与调用顺序一致。
+// 互不依赖的调用最多以 workers 个并发执行；标记为 Serial 的调用会等待之前的调用全部完成后单独执行，
+// 之后的调用也要等它完成才开始，保证写入和命令的先后顺序与模型给出的一致
+func ExecuteTools(ctx context.Context, tools []Tool, registry *Registry, workers int) []ToolCallResponse {
 	responses := make([]ToolCallResponse, len(tools))
-
-	for i, tool := range tools {
-		responses[i] = ExecuteTool(ctx, tool, executor)
+	if workers <= 0 {
+		workers = DEFAULT_TOOL_WORKERS
 	}
 
-	return responses
-}
-
-// ExtractTools 从模型回复中提取工具调用
-func ExtractTools(content string) ([]Tool, bool) {
-	logger.Debug("开始提取工具调用", zap.Int("content_length", len(content)))
-
-	// 查找工具调用的JSON格式 - 支持多种格式
-	var jsonStr string
-
-	// 尝试查找```json格式
-	if start := strings.Index(content, "```json"); start != -1 {
-		end := strings.Index(content[start:], "```")
-		if end != -1 {
-			jsonStr = strings.TrimSpace(content[start+7 : start+end])
-			logger.Debug("找到```json格式的JSON")
+	start := time.Now()
+	batchStart := 0
+	for i, tool := range tools {
+		if !registry.IsSerial(tool) {
+			continue
 		}
+		executeParallel(ctx, tools[batchStart:i], registry, workers, responses[batchStart:i])
+		responses[i] = ExecuteTool(ctx, tool, registry)
+		batchStart = i + 1
 	}
+	executeParallel(ctx, tools[batchStart:], registry, workers, responses[batchStart:])
 
-	// 如果没有找到```json，尝试查找普通的```格式
-	if jsonStr == "" {
-		if start := strings.Index(content, "```"); start != -1 {
-			end := strings.Index(content[start:], "```")
-			if end != -1 {
-				jsonStr = strings.TrimSpace(content[start+3 : start+end])
-				logger.Debug("找到```格式的JSON")
-			}
-		}
+	if len(tools) > 1 {
+		logger.InfoToFile("批量工具调用完成", zap.Int("数量", len(tools)), zap.Int("并发数", workers), zap.Duration("总耗时", time.Since(start)))
 	}
+	return responses
+}
 
-	// 如果没有找到代码块，尝试直接查找JSON数组
-	if jsonStr == "" {
-		// 查找以[开头的JSON数组
-		start := strings.Index(content, "[{")
-		if start != -1 {
-			// 找到匹配的结束括号
-			bracketCount := 0
-			end := start
-			for i := start; i < len(content); i++ {
-				if content[i] == '[' {
-					bracketCount++
-				} else if content[i] == ']' {
-					bracketCount--
-					if bracketCount == 0 {
-						end = i + 1
-						break
-					}
-				}
-			}
-			if end > start {
-				jsonStr = strings.TrimSpace(content[start:end])
-				logger.Info("找到JSON数组格式")
-			}
-		}
+// executeParallel 以最多 workers 个并发执行一批工具调用，结果写入 responses 的对应位置
+func executeParallel(ctx context.Context, tools []Tool, registry *Registry, workers int, responses []ToolCallResponse) {
+	if len(tools) == 0 {
+		return
 	}
-
-	// 如果还是没有找到，尝试查找单个工具对象
-	if jsonStr == "" {
-		start := strings.Index(content, "{")
-		if start != -1 {
-			// 找到匹配的结束括号
-			bracketCount := 0
-			end := start
-			for i := start; i < len(content); i++ {
-				if content[i] == '{' {
-					bracketCount++
-				} else if content[i] == '}' {
-					bracketCount--
-					if bracketCount == 0 {
-						end = i + 1
-						break
-					}
-				}
-			}
-			if end > start {
-				jsonStr = strings.TrimSpace(content[start:end])
-				logger.Info("找到单个JSON对象格式")
-			}
+	if workers == 1 || len(tools) == 1 {
+		for i, tool := range tools {
+			responses[i] = ExecuteTool(ctx, tool, registry)
 		}
+		return
 	}
 
-	if jsonStr == "" {
-		logger.Info("未找到任何JSON格式的工具调用")
-		return nil, false
-	}
-
-	logger.Info("提取到的JSON字符串", zap.String("json_string", jsonStr))
-
-	// 解析JSON
-	var tools []Tool
-	err := json.Unmarshal([]byte(jsonStr), &tools)
-	if err != nil {
-		logger.Error("解析JSON数组失败，尝试解析单个工具", zap.Error(err))
-		// 尝试解析单个工具
-		var singleTool Tool
-		err = json.Unmarshal([]byte(jsonStr), &singleTool)
-		if err != nil {
-			logger.Error("无法解析工具调用", zap.Error(err))
-			logger.Info("原始JSON", zap.String("original_json", jsonStr))
-			return nil, false
-		}
-		tools = []Tool{singleTool}
-		logger.Info("成功解析单个工具")
-	} else {
-		logger.Info("成功解析工具数组", zap.Int("tool_count", len(tools)))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, workers)
+	for i, tool := range tools {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, tool Tool) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			responses[i] = ExecuteTool(ctx, tool, registry)
+		}(i, tool)
 	}
-
-	return tools, len(tools) > 0
+	wg.Wait()
 }
 
-// HandleToolCall 处理用户直接输入的工具调用命令
-func HandleToolCall(ctx context.Context, command string, executor ToolExecutor) {
+// ParseToolCommand 解析用户直接输入的 /tool <工具类型> <工具名称> [JSON参数] 命令，只输入 /tool 时返回 nil
+func ParseToolCommand(command string) (*Tool, error) {
 	parts := strings.Fields(command)
+	if len(parts) == 1 {
+		return nil, nil
+	}
 	if len(parts) < 3 {
-		fmt.Println("错误: 无效的工具命令，格式应为 /tool <工具类型> <工具名称> [参数]")
-		return
+		return nil, fmt.Errorf("无效的工具命令，格式应为 /tool <工具类型> <工具名称> [JSON参数]")
 	}
 
-	toolType := parts[1]
-	toolName := parts[2]
-
 	// 解析参数
 	args := make(map[string]interface{})
 	if len(parts) > 3 {
 		argStr := strings.Join(parts[3:], " ")
-
-		err := json.Unmarshal([]byte(argStr), &args)
-		if err != nil {
-			logger.Error("tools 错误: 无法解析参数", zap.Error(err))
-			return
+		if err := json.Unmarshal([]byte(argStr), &args); err != nil {
+			return nil, fmt.Errorf("无法解析参数，参数应为JSON对象: %v", err)
 		}
 	}
 
-	// 创建工具调用
-	tool := Tool{
-		Type: toolType,
-		Name: toolName,
-		Args: args,
-	}
-
-	// 执行工具调用
-	response := ExecuteTool(ctx, tool, executor)
-
-	// 打印结果
-	logger.Debug("tools 工具结果", zap.String("result", response.Result))
+	return &Tool{Type: parts[1], Name: parts[2], Args: args}, nil
+}
 
-	if response.Error != "" {
-		logger.Error("tools 调用错误", zap.String("error", response.Error))
+// FormatToolResponse 格式化单个工具调用结果，用于原生函数调用的 tool 消息
+func FormatToolResponse(resp ToolCallResponse) string {
+	if resp.Error != "" {
+		result := fmt.Sprintf("状态: 失败\n错误信息: %s\n", resp.Error)
+		if resp.Result != "" {
+			result += fmt.Sprintf("输出:\n%s\n", resp.Result)
+		}
+		return result
 	}
+	return fmt.Sprintf("状态: 成功\n执行结果:\n%s\n", resp.Result)
 }
 
-// FormatToolResponses 格式化工具调用结果
-func FormatToolResponses(responses []ToolCallResponse) string {
+// FormatToolResponses 格式化工具调用结果，每个结果都注明对应的调用
+func FormatToolResponses(tools []Tool, responses []ToolCallResponse) string {
 	result := "工具执行结果:\n"
 	result += "==============\n\n"
 
 	for i, resp := range responses {
 		result += fmt.Sprintf("工具调用 %d:\n", i+1)
+		if i < len(tools) {
+			args, _ := json.Marshal(tools[i].Args)
+			result += fmt.Sprintf("调用: %s.%s %s\n", tools[i].Type, tools[i].Name, args)
+		}
 		result += fmt.Sprintf("状态: %s\n", func() string {
 			if resp.Error != "" {
 				return "失败"
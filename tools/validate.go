@@ -0,0 +1,236 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError 工具参数校验失败，错误信息会原样返回给模型以便其修正调用
+type ValidationError struct {
+	Tool     string   // 工具标识，形如 file_operation.read
+	Problems []string // 每条问题一行
+	Schema   *Schema  // 期望的参数 Schema
+}
+
+// Error 实现 error 接口，列出所有问题和期望的参数格式
+func (e *ValidationError) Error() string {
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("参数校验失败 (%s)，请修正后重新调用:\n", e.Tool))
+	for _, problem := range e.Problems {
+		message.WriteString("- " + problem + "\n")
+	}
+	if e.Schema != nil {
+		expected, _ := json.Marshal(e.Schema)
+		message.WriteString(fmt.Sprintf("期望的参数格式: %s", expected))
+	}
+	return strings.TrimRight(message.String(), "\n")
+}
+
+// ValidateArgs 按工具定义校验模型给出的参数，通过时返回 nil
+func ValidateArgs(definition ToolDefinition, args map[string]interface{}) *ValidationError {
+	if definition.Parameters == nil {
+		return nil
+	}
+
+	problems := validateObject("", definition.Parameters, args)
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Tool: definition.Key(), Problems: problems, Schema: definition.Parameters}
+}
+
+// validateObject 校验对象：必填字段、未知字段和每个字段的值
+func validateObject(path string, schema *Schema, object map[string]interface{}) []string {
+	var problems []string
+
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: 缺少必填参数", fieldPath(path, name)))
+		}
+	}
+
+	// 按名称排序，保证错误信息稳定
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := schema.Properties[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: 未知参数，可用参数: %s", fieldPath(path, name), strings.Join(propertyNames(schema), ", ")))
+			continue
+		}
+		problems = append(problems, validateValue(fieldPath(path, name), property, object[name])...)
+	}
+
+	return problems
+}
+
+// validateValue 校验单个值的类型、枚举、长度和范围
+func validateValue(path string, schema *Schema, value interface{}) []string {
+	if value == nil {
+		return []string{fmt.Sprintf("%s: 值不能为 null，应为 %s", path, schema.Type)}
+	}
+
+	switch schema.Type {
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+		var problems []string
+		if len(schema.Enum) > 0 && !containsString(schema.Enum, text) {
+			problems = append(problems, fmt.Sprintf("%s: 值 %q 无效，可选值: %s", path, text, strings.Join(schema.Enum, ", ")))
+		}
+		length := utf8.RuneCountInString(text)
+		if length < schema.MinLength {
+			problems = append(problems, fmt.Sprintf("%s: 长度至少为 %d，实际为 %d", path, schema.MinLength, length))
+		}
+		if schema.MaxLength > 0 && length > schema.MaxLength {
+			problems = append(problems, fmt.Sprintf("%s: 长度不能超过 %d，实际为 %d", path, schema.MaxLength, length))
+		}
+		return problems
+
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+		if schema.Type == "integer" && number != math.Trunc(number) {
+			return []string{fmt.Sprintf("%s: 应为整数，实际为 %v", path, number)}
+		}
+		var problems []string
+		if schema.Minimum != nil && number < *schema.Minimum {
+			problems = append(problems, fmt.Sprintf("%s: 不能小于 %v，实际为 %v", path, *schema.Minimum, number))
+		}
+		if schema.Maximum != nil && number > *schema.Maximum {
+			problems = append(problems, fmt.Sprintf("%s: 不能大于 %v，实际为 %v", path, *schema.Maximum, number))
+		}
+		return problems
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+		return nil
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+		if schema.Items == nil {
+			return nil
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, validateValue(fmt.Sprintf("%s[%d]", path, i), schema.Items, item)...)
+		}
+		return problems
+
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{typeMismatch(path, schema.Type, value)}
+		}
+		return validateObject(path, schema, object)
+
+	default:
+		return nil
+	}
+}
+
+// typeMismatch 生成类型不匹配的错误信息
+func typeMismatch(path, expected string, value interface{}) string {
+	return fmt.Sprintf("%s: 类型应为 %s，实际为 %s", path, expected, jsonTypeName(value))
+}
+
+// jsonTypeName 返回 JSON 解码后的值对应的类型名
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// fieldPath 拼接字段路径
+func fieldPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// propertyNames 返回 Schema 中所有属性名
+func propertyNames(schema *Schema) []string {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// stringArg 读取已校验的字符串参数，缺省时返回默认值
+func stringArg(args map[string]interface{}, name, defaultValue string) string {
+	if value, ok := args[name].(string); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// boolArg 读取已校验的布尔参数，缺省时返回默认值
+func boolArg(args map[string]interface{}, name string, defaultValue bool) bool {
+	if value, ok := args[name].(bool); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// intArg 读取已校验的整数参数，缺省时返回默认值
+func intArg(args map[string]interface{}, name string, defaultValue int) int {
+	if value, ok := args[name].(float64); ok {
+		return int(value)
+	}
+	return defaultValue
+}
+
+// stringsArg 读取已校验的字符串数组参数，缺省时返回 nil
+func stringsArg(args map[string]interface{}, name string) []string {
+	items, _ := args[name].([]interface{})
+	var values []string
+	for _, item := range items {
+		if value, ok := item.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultIgnorePatterns 遍历项目目录时默认跳过的目录
+var defaultIgnorePatterns = []string{".git/", "node_modules/", ".simple-agent/"}
+
+// ignorePatterns 通过 SetIgnorePatterns 配置的额外忽略模式，启动时设置
+var ignorePatterns []string
+
+// SetIgnorePatterns 设置遍历项目目录时额外忽略的模式，语法与 .gitignore 相同
+func SetIgnorePatterns(patterns []string) {
+	ignorePatterns = append([]string(nil), patterns...)
+}
+
+// ignoreRule 一条忽略规则，语法与 .gitignore 相同
+type ignoreRule struct {
+	base     string // 规则所在的目录，相对于项目根目录，根目录为空
+	pattern  string // glob 模式
+	negate   bool   // 以 ! 开头，重新包含之前被忽略的路径
+	dirOnly  bool   // 以 / 结尾，只匹配目录
+	anchored bool   // 模式中含有 /，相对于 base 匹配完整路径，否则匹配任意层级的文件名
+}
+
+// parseIgnoreRule 解析一行忽略规则，空行和注释返回 false
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.pattern = line
+	return rule, true
+}
+
+// matches 判断相对于项目根目录的路径是否匹配规则
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if r.anchored {
+		return MatchGlob(r.pattern, rel)
+	}
+	return MatchGlob(r.pattern, path.Base(rel))
+}
+
+// ignoreMatcher 按顺序应用的一组忽略规则，后面的规则优先
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// newIgnoreMatcher 创建包含默认规则、配置的忽略模式和额外模式的忽略规则
+func newIgnoreMatcher(extra []string) *ignoreMatcher {
+	matcher := &ignoreMatcher{}
+	for _, patterns := range [][]string{defaultIgnorePatterns, ignorePatterns, extra} {
+		for _, pattern := range patterns {
+			if rule, ok := parseIgnoreRule("", pattern); ok {
+				matcher.rules = append(matcher.rules, rule)
+			}
+		}
+	}
+	return matcher
+}
+
+// loadGitignore 读取目录中的 .gitignore 并追加其中的规则，dir 为相对于项目根目录的路径
+func (m *ignoreMatcher) loadGitignore(dir string) {
+	file, err := os.Open(filepath.Join(filepath.FromSlash(dir), ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	base := dir
+	if base == "." {
+		base = ""
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(base, scanner.Text()); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+}
+
+// ignored 判断路径是否被忽略
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.matches(rel, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// walkWorkspace 遍历 root 下的文件和目录，跳过默认忽略的目录、各级 .gitignore 中的路径和 extraIgnore 中的模式。
+// 回调中的路径以 / 分隔、相对于项目根目录；返回 filepath.SkipDir 跳过目录，返回其他错误时停止遍历
+func walkWorkspace(root string, extraIgnore []string, fn func(rel string, info os.FileInfo) error) error {
+	matcher := newIgnoreMatcher(extraIgnore)
+	root = filepath.ToSlash(filepath.Clean(root))
+
+	// 先加载项目根目录到 root 各级目录中的 .gitignore，从子目录开始遍历时也遵守上层的规则
+	matcher.loadGitignore(".")
+	if root != "." {
+		parts := strings.Split(root, "/")
+		for i := 1; i <= len(parts); i++ {
+			matcher.loadGitignore(strings.Join(parts[:i], "/"))
+		}
+	}
+
+	return filepath.Walk(filepath.FromSlash(root), func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 无法访问的文件和目录直接跳过
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel := filepath.ToSlash(name)
+		if rel != root {
+			if matcher.ignored(rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				matcher.loadGitignore(rel)
+			}
+		}
+		return fn(rel, info)
+	})
+}
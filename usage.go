@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"simple-agent/llm"
+)
+
+// usageTracker 统计最近一次请求、当前回合和整个会话的token用量
+type usageTracker struct {
+	last     llm.Usage // 最近一次请求的用量
+	turn     llm.Usage // 当前回合累计用量
+	session  llm.Usage // 会话累计用量
+	requests int       // 会话内的模型请求次数
+}
+
+// beginTurn 开始新回合，清空回合用量
+func (t *usageTracker) beginTurn() {
+	t.turn = llm.Usage{}
+}
+
+// record 记录一次模型请求的用量
+func (t *usageTracker) record(usage llm.Usage) {
+	// 部分服务只返回输入和输出，补齐总数
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	t.last = usage
+	t.turn.Add(usage)
+	t.session.Add(usage)
+	t.requests++
+}
+
+// exceeded 检查是否超出预算，返回超出原因，未超出时返回空字符串；预算为0表示不限制
+func (t *usageTracker) exceeded(maxTurnTokens, maxSessionTokens int) string {
+	if maxSessionTokens > 0 && t.session.TotalTokens >= maxSessionTokens {
+		return fmt.Sprintf("会话已使用 %d tokens，达到会话预算 %d", t.session.TotalTokens, maxSessionTokens)
+	}
+	if maxTurnTokens > 0 && t.turn.TotalTokens >= maxTurnTokens {
+		return fmt.Sprintf("本轮已使用 %d tokens，达到单轮预算 %d", t.turn.TotalTokens, maxTurnTokens)
+	}
+	return ""
+}
+
+// summary 返回回复后显示的一行用量摘要
+func (t *usageTracker) summary() string {
+	return fmt.Sprintf("tokens 本次: 输入 %d / 输出 %d | 本轮: %d | 会话: %d",
+		t.last.PromptTokens, t.last.CompletionTokens, t.turn.TotalTokens, t.session.TotalTokens)
+}
+
+// report 返回 /usage 命令显示的详细用量
+func (t *usageTracker) report(maxTurnTokens, maxSessionTokens int) string {
+	budget := func(limit int) string {
+		if limit <= 0 {
+			return "不限制"
+		}
+		return fmt.Sprintf("%d", limit)
+	}
+
+	return fmt.Sprintf(`token 用量统计:
+  本轮: 输入 %d / 输出 %d / 合计 %d（预算: %s）
+  会话: 输入 %d / 输出 %d / 合计 %d（预算: %s）
+  模型请求次数: %d`,
+		t.turn.PromptTokens, t.turn.CompletionTokens, t.turn.TotalTokens, budget(maxTurnTokens),
+		t.session.PromptTokens, t.session.CompletionTokens, t.session.TotalTokens, budget(maxSessionTokens),
+		t.requests)
+}